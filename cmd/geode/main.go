@@ -1,12 +1,19 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"geode/internal/build"
 	"geode/internal/config"
+	"geode/internal/content"
+	"geode/internal/render"
 	"geode/internal/server"
+	"geode/internal/types"
 	"log"
 	"os"
+	"slices"
+	"strings"
 )
 
 func main() {
@@ -23,6 +30,15 @@ func main() {
 	case "build":
 		runBuild(os.Args[2:])
 
+	case "check":
+		runCheck(os.Args[2:])
+
+	case "graph":
+		runGraph(os.Args[2:])
+
+	case "stats":
+		runStats(os.Args[2:])
+
 	default:
 		fmt.Println("Unknown command:", os.Args[1])
 		printUsage()
@@ -34,6 +50,9 @@ func runServe(args []string) {
 	serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
 	port := serveCmd.Int("port", 3001, "application port")
 	contentDir := serveCmd.String("dir", "content", "content directory")
+	jobs := serveCmd.Int("jobs", 0, "number of pages rendered in parallel (0 uses every CPU)")
+	noCache := serveCmd.Bool("no-cache", false, "render every note without reading or writing the build cache")
+	outputDir := serveCmd.String("out", "", "output directory (default build.output)")
 
 	serveCmd.Parse(args)
 
@@ -41,22 +60,27 @@ func runServe(args []string) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	applyBuildFlags(cfg, *jobs, *noCache, *outputDir)
 
-	fmt.Printf("Serving %s at http://localhost:%d\n", *contentDir, *port)
+	fmt.Printf("Serving %s at http://localhost:%d%s/\n", *contentDir, *port, cfg.Site.BasePath)
 
 	// build once
-	err = server.Rebuild(*contentDir, cfg, true)
+	builder := server.NewBuilder(*contentDir, cfg, true)
+	err = builder.Build()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	go server.WatchAndRebuild(*contentDir, cfg)
-	server.ServePublic(*port)
+	go server.WatchAndRebuild(builder)
+	server.ServePublic(cfg, *port)
 }
 
 func runBuild(args []string) {
 	buildCmd := flag.NewFlagSet("build", flag.ExitOnError)
 	contentDir := buildCmd.String("dir", "content", "content directory")
+	jobs := buildCmd.Int("jobs", 0, "number of pages rendered in parallel (0 uses every CPU)")
+	noCache := buildCmd.Bool("no-cache", false, "render every note without reading or writing the build cache")
+	outputDir := buildCmd.String("out", "", "output directory (default build.output)")
 
 	buildCmd.Parse(args)
 
@@ -64,6 +88,7 @@ func runBuild(args []string) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	applyBuildFlags(cfg, *jobs, *noCache, *outputDir)
 
 	fmt.Println("Building from:", *contentDir)
 	err = server.Rebuild(*contentDir, cfg, false)
@@ -72,8 +97,219 @@ func runBuild(args []string) {
 	}
 }
 
+func runCheck(args []string) {
+	checkCmd := flag.NewFlagSet("check", flag.ExitOnError)
+	contentDir := checkCmd.String("dir", "content", "content directory")
+	format := checkCmd.String("format", "text", "output format: text or json")
+	strict := checkCmd.Bool("strict", false, "exit with an error on warnings too")
+
+	checkCmd.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		log.Fatalf("unknown format %q, expected text or json", *format)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	entries, err := content.GetAllMarkdownAndAssets(*contentDir, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	site := render.NewSite(content.FilterEntries(entries, cfg), cfg)
+	issues := site.Check()
+	for _, c := range build.URLCollisions(cfg, site.Pages) {
+		issues = append(issues, render.Issue{
+			File:     c.Page.Path,
+			Line:     1,
+			Column:   1,
+			Kind:     render.IssueURLCollision,
+			Severity: render.SeverityError,
+			Target:   c.Page.Link,
+			Message:  c.String(),
+		})
+	}
+	render.SortIssues(issues)
+
+	errorCount, warningCount := 0, 0
+	for _, issue := range issues {
+		if issue.Severity == render.SeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+
+	if *format == "json" {
+		if issues == nil {
+			issues = []render.Issue{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(issues); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		fmt.Printf("%d errors, %d warnings in %d notes\n", errorCount, warningCount, len(site.Pages))
+	}
+
+	if errorCount > 0 || (*strict && warningCount > 0) {
+		os.Exit(1)
+	}
+}
+
+func runGraph(args []string) {
+	if len(args) < 1 || args[0] != "export" {
+		fmt.Println("Usage: geode graph export [flags]")
+		os.Exit(1)
+	}
+
+	exportCmd := flag.NewFlagSet("graph export", flag.ExitOnError)
+	contentDir := exportCmd.String("dir", "content", "content directory")
+	format := exportCmd.String("format", "json", "output format: "+strings.Join(build.GraphExportFormats, ", "))
+	output := exportCmd.String("o", "", "output file (default stdout)")
+	tags := exportCmd.Bool("tags", false, "include tags as nodes (default graph.tags)")
+
+	exportCmd.Parse(args[1:])
+
+	if !slices.Contains(build.GraphExportFormats, *format) {
+		log.Fatalf("unknown format %q, expected one of %s", *format, strings.Join(build.GraphExportFormats, ", "))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Like stats, the export covers every published note; -tags, when given,
+	// overrides graph.tags either way.
+	exportCmd.Visit(func(f *flag.Flag) {
+		if f.Name == "tags" {
+			cfg.Graph.Tags = *tags
+		}
+	})
+	cfg.Graph.HideOrphans = false
+	cfg.Graph.ExcludeFolders = nil
+	cfg.Graph.ExcludeTags = nil
+
+	entries, err := content.GetAllMarkdownAndAssets(*contentDir, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pages := render.ParsingMarkdown(content.FilterEntries(entries, cfg), cfg)
+	graph := build.BuildGraph(cfg, pages)
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := build.ExportGraph(out, graph, *format); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runStats(args []string) {
+	statsCmd := flag.NewFlagSet("stats", flag.ExitOnError)
+	contentDir := statsCmd.String("dir", "content", "content directory")
+	format := statsCmd.String("format", "text", "output format: text or json")
+	limit := statsCmd.Int("limit", 10, "number of notes listed per ranking")
+
+	statsCmd.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		log.Fatalf("unknown format %q, expected text or json", *format)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Stats cover every published note, whatever the graph panels show.
+	cfg.Graph.Tags = false
+	cfg.Graph.HideOrphans = false
+	cfg.Graph.ExcludeFolders = nil
+	cfg.Graph.ExcludeTags = nil
+
+	entries, err := content.GetAllMarkdownAndAssets(*contentDir, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pages := render.ParsingMarkdown(content.FilterEntries(entries, cfg), cfg)
+	graph := build.BuildGraph(cfg, pages)
+	metrics := build.ComputeGraphMetrics(graph)
+	mostReferenced := build.MostReferenced(graph, *limit)
+	pageRank := build.TopPageRank(graph, *limit)
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			build.GraphMetrics
+			MostReferenced []types.GraphNode `json:"mostReferenced"`
+			PageRank       []types.GraphNode `json:"pageRank"`
+		}{metrics, mostReferenced, pageRank})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	fmt.Printf("Notes: %d\n", metrics.Notes)
+	fmt.Printf("Links: %d\n", metrics.Links)
+	fmt.Printf("Connected components: %d\n", metrics.Components)
+
+	fmt.Println("\nMost referenced:")
+	for i, node := range mostReferenced {
+		fmt.Printf("  %2d. %s (%d backlinks)\n", i+1, node.Title, node.InDegree)
+	}
+
+	fmt.Println("\nPageRank:")
+	for i, node := range pageRank {
+		fmt.Printf("  %2d. %s (%.4f, in %d, out %d)\n", i+1, node.Title, node.PageRank, node.InDegree, node.OutDegree)
+	}
+
+	fmt.Printf("\nOrphans (%d):\n", len(metrics.Orphans))
+	for _, node := range metrics.Orphans {
+		fmt.Printf("  %s\n", node.URL)
+	}
+
+	fmt.Printf("\nDead ends (%d):\n", len(metrics.DeadEnds))
+	for _, node := range metrics.DeadEnds {
+		fmt.Printf("  %s\n", node.URL)
+	}
+}
+
+func applyBuildFlags(cfg *config.Config, jobs int, noCache bool, outputDir string) {
+	if jobs > 0 {
+		cfg.Build.Jobs = jobs
+	}
+	if noCache {
+		cfg.Build.Cache = ""
+	}
+	if outputDir != "" {
+		cfg.Build.Output = outputDir
+	}
+}
+
 func printUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("  geode build [flags]")
 	fmt.Println("  geode serve [flags]")
+	fmt.Println("  geode check [flags]")
+	fmt.Println("  geode graph export [flags]")
+	fmt.Println("  geode stats [flags]")
 }
@@ -0,0 +1,187 @@
+package bases
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Ext is the file extension of Obsidian bases.
+const Ext = ".base"
+
+// Base is a parsed .base file: filters shared by every view, formulas,
+// property display names and the views themselves.
+type Base struct {
+	Filters    Filter              `yaml:"filters"`
+	Formulas   map[string]string   `yaml:"formulas"`
+	Properties map[string]Property `yaml:"properties"`
+	Views      []View              `yaml:"views"`
+
+	formulas map[string]expr
+	columns  map[string]expr
+	warnings []string
+}
+
+type Property struct {
+	DisplayName string `yaml:"displayName"`
+}
+
+type View struct {
+	Type    string   `yaml:"type"`
+	Name    string   `yaml:"name"`
+	Limit   int      `yaml:"limit"`
+	Filters Filter   `yaml:"filters"`
+	Order   []string `yaml:"order"`
+	Sort    []Sort   `yaml:"sort"`
+	GroupBy *Sort    `yaml:"groupBy"`
+	Image   string   `yaml:"image"`
+}
+
+type Sort struct {
+	Property  string `yaml:"property"`
+	Direction string `yaml:"direction"`
+}
+
+func (s Sort) desc() bool {
+	return s.Direction == "DESC" || s.Direction == "desc"
+}
+
+// Filter is either a single expression or a list of filters combined with
+// and, or or not.
+type Filter struct {
+	Expr string
+	And  []Filter
+	Or   []Filter
+	Not  []Filter
+
+	compiled expr
+}
+
+func (f *Filter) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&f.Expr)
+	}
+
+	var group struct {
+		And []Filter `yaml:"and"`
+		Or  []Filter `yaml:"or"`
+		Not []Filter `yaml:"not"`
+	}
+	if err := node.Decode(&group); err != nil {
+		return err
+	}
+	f.And, f.Or, f.Not = group.And, group.Or, group.Not
+	return nil
+}
+
+func (f *Filter) empty() bool {
+	return f.Expr == "" && len(f.And) == 0 && len(f.Or) == 0 && len(f.Not) == 0
+}
+
+// Load reads and parses the base at path.
+func Load(path string) (*Base, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses a base. Only invalid YAML is an error: expressions that do not
+// parse or use unsupported functions are reported by Warnings and skipped
+// when the base is rendered.
+func Parse(data []byte) (*Base, error) {
+	b := &Base{}
+	if err := yaml.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("parse base: %w", err)
+	}
+
+	b.formulas = make(map[string]expr, len(b.Formulas))
+	names := make([]string, 0, len(b.Formulas))
+	for name := range b.Formulas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.formulas[name] = b.compile(fmt.Sprintf("formula %q", name), b.Formulas[name])
+	}
+
+	b.compileFilter("filter", &b.Filters)
+
+	b.columns = make(map[string]expr)
+	for i := range b.Views {
+		v := &b.Views[i]
+		b.compileFilter(fmt.Sprintf("view %q filter", v.Name), &v.Filters)
+
+		props := append([]string(nil), v.Order...)
+		for _, s := range v.Sort {
+			props = append(props, s.Property)
+		}
+		if v.GroupBy != nil {
+			props = append(props, v.GroupBy.Property)
+		}
+		if v.Image != "" {
+			props = append(props, v.Image)
+		}
+		for _, p := range props {
+			if _, ok := b.columns[p]; !ok {
+				b.columns[p] = b.compile(fmt.Sprintf("view %q property", v.Name), p)
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// Warnings returns the problems found while parsing the base.
+func (b *Base) Warnings() []string {
+	return b.warnings
+}
+
+func (b *Base) compileFilter(where string, f *Filter) {
+	if f.Expr != "" {
+		f.compiled = b.compile(where, f.Expr)
+	}
+	for _, group := range [][]Filter{f.And, f.Or, f.Not} {
+		for i := range group {
+			b.compileFilter(where, &group[i])
+		}
+	}
+}
+
+// compile parses src and checks its function names, returning nil when the
+// expression cannot be used.
+func (b *Base) compile(where, src string) expr {
+	x, err := parseExpr(src)
+	if err != nil {
+		b.warnings = append(b.warnings, fmt.Sprintf("%s %q: %v", where, src, err))
+		return nil
+	}
+	if err := check(x); err != nil {
+		b.warnings = append(b.warnings, fmt.Sprintf("%s %q: %v", where, src, err))
+		return nil
+	}
+	return x
+}
+
+// HasView reports whether the base has a view called name.
+func (b *Base) HasView(name string) bool {
+	_, ok := b.view(name)
+	return ok
+}
+
+// view returns the view called name, or the first view when name is empty.
+// A base without views shows its notes in a table.
+func (b *Base) view(name string) (View, bool) {
+	if len(b.Views) == 0 && name == "" {
+		return View{Type: "table"}, true
+	}
+	for _, v := range b.Views {
+		if name == "" || v.Name == name {
+			return v, true
+		}
+	}
+	return View{}, false
+}
@@ -0,0 +1,1247 @@
+package bases
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"geode/internal/types"
+)
+
+// Env is what expressions are evaluated against.
+type Env struct {
+	// Notes are the published notes a view picks its rows from.
+	Notes []types.MetaMarkdown
+	// This is the note embedding the base, or nil.
+	This *types.MetaMarkdown
+	// Resolve returns the URL of a wikilink target, like "Note" in "[[Note]]".
+	Resolve func(target string) (string, bool)
+	Now     time.Time
+}
+
+// fileRef is the value of file and of this.file.
+type fileRef struct{ note *types.MetaMarkdown }
+
+// linkValue is a link built by link() or read from a "[[Note]]" property.
+type linkValue struct {
+	target string
+	text   string
+}
+
+var globals = map[string]bool{
+	"if": true, "now": true, "today": true, "date": true, "number": true,
+	"list": true, "link": true, "min": true, "max": true,
+}
+
+var methods = map[string]bool{
+	// file
+	"hasTag": true, "inFolder": true, "hasLink": true, "hasProperty": true, "asLink": true,
+	// strings and lists
+	"contains": true, "containsAll": true, "containsAny": true, "startsWith": true, "endsWith": true,
+	"isEmpty": true, "lower": true, "upper": true, "title": true, "trim": true, "replace": true,
+	"split": true, "slice": true, "join": true, "unique": true, "sort": true, "reverse": true,
+	"flat": true,
+	// numbers
+	"abs": true, "ceil": true, "floor": true, "round": true, "toFixed": true,
+	// dates
+	"format": true, "date": true, "time": true,
+	// any
+	"toString": true, "isTruthy": true, "isType": true,
+}
+
+// check reports calls to functions the evaluator does not know.
+func check(x expr) error {
+	switch x := x.(type) {
+	case member:
+		return check(x.x)
+	case index:
+		if err := check(x.x); err != nil {
+			return err
+		}
+		return check(x.i)
+	case unary:
+		return check(x.x)
+	case binary:
+		if err := check(x.x); err != nil {
+			return err
+		}
+		return check(x.y)
+	case listLit:
+		for _, item := range x.items {
+			if err := check(item); err != nil {
+				return err
+			}
+		}
+	case call:
+		switch fn := x.fn.(type) {
+		case ident:
+			if !globals[fn.name] {
+				return fmt.Errorf("unsupported function %s()", fn.name)
+			}
+		case member:
+			if !methods[fn.name] {
+				return fmt.Errorf("unsupported function %s()", fn.name)
+			}
+			if err := check(fn.x); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("expression is not callable")
+		}
+		for _, arg := range x.args {
+			if err := check(arg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// row is a note being evaluated, with its formulas computed on demand.
+type row struct {
+	note     *types.MetaMarkdown
+	formulas map[string]any
+	pending  map[string]bool
+}
+
+func newRow(note *types.MetaMarkdown) *row {
+	return &row{note: note, formulas: make(map[string]any), pending: make(map[string]bool)}
+}
+
+type evaluator struct {
+	base *Base
+	env  Env
+	this *row
+}
+
+func (e *evaluator) eval(x expr, r *row) (any, error) {
+	switch x := x.(type) {
+	case literal:
+		return x.value, nil
+
+	case ident:
+		switch x.name {
+		case "file":
+			return fileRef{r.note}, nil
+		case "note":
+			return properties(r.note), nil
+		case "formula":
+			return r, nil
+		case "this":
+			if e.this == nil {
+				return nil, nil
+			}
+			return thisRef{e.this}, nil
+		}
+		return property(r.note, x.name), nil
+
+	case listLit:
+		items := make([]any, len(x.items))
+		for i, item := range x.items {
+			v, err := e.eval(item, r)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = v
+		}
+		return items, nil
+
+	case member:
+		recv, err := e.eval(x.x, r)
+		if err != nil {
+			return nil, err
+		}
+		return e.field(recv, x.name)
+
+	case index:
+		recv, err := e.eval(x.x, r)
+		if err != nil {
+			return nil, err
+		}
+		i, err := e.eval(x.i, r)
+		if err != nil {
+			return nil, err
+		}
+		if name, ok := i.(string); ok {
+			return e.field(recv, name)
+		}
+		list, ok := recv.([]any)
+		n, isNum := i.(float64)
+		if !ok || !isNum {
+			return nil, fmt.Errorf("cannot index %s", typeName(recv))
+		}
+		k := int(n)
+		if k < 0 {
+			k += len(list)
+		}
+		if k < 0 || k >= len(list) {
+			return nil, nil
+		}
+		return list[k], nil
+
+	case unary:
+		v, err := e.eval(x.x, r)
+		if err != nil {
+			return nil, err
+		}
+		if x.op == "!" {
+			return !truthy(v), nil
+		}
+		n, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate %s", typeName(v))
+		}
+		return -n, nil
+
+	case binary:
+		return e.binary(x, r)
+
+	case call:
+		return e.call(x, r)
+	}
+
+	return nil, fmt.Errorf("unsupported expression")
+}
+
+// thisRef is the value of this: the note embedding the base.
+type thisRef struct{ row *row }
+
+func (e *evaluator) field(recv any, name string) (any, error) {
+	switch v := recv.(type) {
+	case nil:
+		return nil, nil
+
+	case thisRef:
+		switch name {
+		case "file":
+			return fileRef{v.row.note}, nil
+		case "note":
+			return properties(v.row.note), nil
+		case "formula":
+			return v.row, nil
+		}
+		return property(v.row.note, name), nil
+
+	case *row:
+		return e.formula(v, name)
+
+	case fileRef:
+		return e.fileField(v.note, name)
+
+	case map[string]any:
+		return normalize(v[name]), nil
+
+	case string:
+		if name == "length" {
+			return float64(len([]rune(v))), nil
+		}
+
+	case []any:
+		if name == "length" {
+			return float64(len(v)), nil
+		}
+
+	case time.Time:
+		switch name {
+		case "year":
+			return float64(v.Year()), nil
+		case "month":
+			return float64(v.Month()), nil
+		case "day":
+			return float64(v.Day()), nil
+		case "hour":
+			return float64(v.Hour()), nil
+		case "minute":
+			return float64(v.Minute()), nil
+		case "second":
+			return float64(v.Second()), nil
+		}
+
+	case linkValue:
+		switch name {
+		case "target":
+			return v.target, nil
+		case "text":
+			return v.text, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s has no property %q", typeName(recv), name)
+}
+
+func (e *evaluator) formula(r *row, name string) (any, error) {
+	if v, ok := r.formulas[name]; ok {
+		return v, nil
+	}
+
+	x, ok := e.base.formulas[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown formula %q", name)
+	}
+	if x == nil {
+		return nil, fmt.Errorf("formula %q is invalid", name)
+	}
+	if r.pending[name] {
+		return nil, fmt.Errorf("formula %q refers to itself", name)
+	}
+
+	r.pending[name] = true
+	v, err := e.eval(x, r)
+	delete(r.pending, name)
+	if err != nil {
+		return nil, err
+	}
+
+	r.formulas[name] = v
+	return v, nil
+}
+
+func (e *evaluator) fileField(note *types.MetaMarkdown, name string) (any, error) {
+	rel := filepath.ToSlash(note.RelativePath)
+	switch name {
+	case "name":
+		return path.Base(rel), nil
+	case "basename":
+		return strings.TrimSuffix(path.Base(rel), path.Ext(rel)), nil
+	case "path":
+		return rel, nil
+	case "folder":
+		if dir := path.Dir(rel); dir != "." {
+			return dir, nil
+		}
+		return "", nil
+	case "ext":
+		return strings.TrimPrefix(path.Ext(rel), "."), nil
+	case "title":
+		return note.Title, nil
+	case "url":
+		return note.Link, nil
+	case "size":
+		info, err := os.Stat(note.Path)
+		if err != nil {
+			return nil, nil
+		}
+		return float64(info.Size()), nil
+	case "mtime":
+		if t, ok := toTime(note.Frontmatter["modified"]); ok {
+			return t, nil
+		}
+		return modTime(note), nil
+	case "ctime":
+		if t, ok := toTime(note.Frontmatter["created"]); ok {
+			return t, nil
+		}
+		return modTime(note), nil
+	case "tags":
+		tags := make([]any, len(note.Tags))
+		for i, t := range note.Tags {
+			tags[i] = t
+		}
+		return tags, nil
+	case "links":
+		links := make([]any, 0, len(note.OutgoingLinks))
+		for _, l := range note.OutgoingLinks {
+			links = append(links, l.URL)
+		}
+		return links, nil
+	case "backlinks":
+		links := make([]any, len(note.Backlinks))
+		for i, l := range note.Backlinks {
+			links[i] = l.URL
+		}
+		return links, nil
+	case "properties":
+		return note.Frontmatter, nil
+	}
+	return nil, fmt.Errorf("file has no property %q", name)
+}
+
+func modTime(note *types.MetaMarkdown) any {
+	info, err := os.Stat(note.Path)
+	if err != nil {
+		return nil
+	}
+	return info.ModTime()
+}
+
+func property(note *types.MetaMarkdown, name string) any {
+	return normalize(properties(note)[name])
+}
+
+// properties returns the frontmatter of note together with its inline
+// fields.
+func properties(note *types.MetaMarkdown) map[string]any {
+	if note.Fields != nil {
+		return note.Fields
+	}
+	return note.Frontmatter
+}
+
+// normalize turns YAML numbers into float64, the only number type of
+// expressions.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	case []string:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = item
+		}
+		return out
+	}
+	return v
+}
+
+func (e *evaluator) binary(x binary, r *row) (any, error) {
+	a, err := e.eval(x.x, r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x.op {
+	case "&&":
+		if !truthy(a) {
+			return false, nil
+		}
+		b, err := e.eval(x.y, r)
+		return truthy(b), err
+	case "||":
+		if truthy(a) {
+			return true, nil
+		}
+		b, err := e.eval(x.y, r)
+		return truthy(b), err
+	}
+
+	b, err := e.eval(x.y, r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x.op {
+	case "==":
+		return e.equal(a, b), nil
+	case "!=":
+		return !e.equal(a, b), nil
+	case "<", ">", "<=", ">=":
+		c, ok := compare(a, b)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s and %s", typeName(a), typeName(b))
+		}
+		switch x.op {
+		case "<":
+			return c < 0, nil
+		case ">":
+			return c > 0, nil
+		case "<=":
+			return c <= 0, nil
+		}
+		return c >= 0, nil
+	}
+
+	if t, ok := a.(time.Time); ok {
+		switch d := b.(type) {
+		case string:
+			if x.op == "+" || x.op == "-" {
+				return addDuration(t, d, x.op == "-")
+			}
+		case time.Time:
+			if x.op == "-" {
+				return float64(t.Sub(d).Milliseconds()), nil
+			}
+		}
+	}
+
+	an, aNum := a.(float64)
+	bn, bNum := b.(float64)
+	if aNum && bNum {
+		switch x.op {
+		case "+":
+			return an + bn, nil
+		case "-":
+			return an - bn, nil
+		case "*":
+			return an * bn, nil
+		case "/":
+			if bn == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return an / bn, nil
+		case "%":
+			if bn == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			return math.Mod(an, bn), nil
+		}
+	}
+
+	if x.op == "+" {
+		if al, ok := a.([]any); ok {
+			if bl, ok := b.([]any); ok {
+				return append(append([]any(nil), al...), bl...), nil
+			}
+		}
+		_, aStr := a.(string)
+		_, bStr := b.(string)
+		if aStr || bStr {
+			return toString(a) + toString(b), nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot apply %s to %s and %s", x.op, typeName(a), typeName(b))
+}
+
+func (e *evaluator) call(x call, r *row) (any, error) {
+	if fn, ok := x.fn.(ident); ok && fn.name == "if" {
+		if len(x.args) < 2 {
+			return nil, fmt.Errorf("if() takes a condition and one or two values")
+		}
+		cond, err := e.eval(x.args[0], r)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return e.eval(x.args[1], r)
+		}
+		if len(x.args) > 2 {
+			return e.eval(x.args[2], r)
+		}
+		return nil, nil
+	}
+
+	args := make([]any, len(x.args))
+	for i, arg := range x.args {
+		v, err := e.eval(arg, r)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch fn := x.fn.(type) {
+	case ident:
+		return e.global(fn.name, args)
+	case member:
+		recv, err := e.eval(fn.x, r)
+		if err != nil {
+			return nil, err
+		}
+		return e.method(recv, fn.name, args)
+	}
+	return nil, fmt.Errorf("expression is not callable")
+}
+
+func (e *evaluator) global(name string, args []any) (any, error) {
+	switch name {
+	case "now":
+		return e.env.Now, nil
+	case "today":
+		y, m, d := e.env.Now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, e.env.Now.Location()), nil
+	case "date":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("date() takes one argument")
+		}
+		t, ok := toTime(args[0])
+		if !ok {
+			return nil, fmt.Errorf("date(): cannot parse %q", toString(args[0]))
+		}
+		return t, nil
+	case "number":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("number() takes one argument")
+		}
+		switch v := args[0].(type) {
+		case float64:
+			return v, nil
+		case bool:
+			if v {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("number(): cannot parse %q", v)
+			}
+			return n, nil
+		case time.Time:
+			return float64(v.UnixMilli()), nil
+		}
+		return nil, fmt.Errorf("number(): cannot convert %s", typeName(args[0]))
+	case "list":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("list() takes one argument")
+		}
+		if l, ok := args[0].([]any); ok {
+			return l, nil
+		}
+		return []any{args[0]}, nil
+	case "link":
+		if len(args) < 1 {
+			return nil, fmt.Errorf("link() takes a target and an optional text")
+		}
+		l := linkValue{target: toString(args[0])}
+		if f, ok := args[0].(fileRef); ok {
+			l = linkValue{target: strings.TrimSuffix(filepath.ToSlash(f.note.RelativePath), ".md"), text: f.note.Title}
+		}
+		if len(args) > 1 {
+			l.text = toString(args[1])
+		}
+		return l, nil
+	case "min", "max":
+		var best any
+		for _, a := range args {
+			if best == nil {
+				best = a
+				continue
+			}
+			c, ok := compare(a, best)
+			if !ok {
+				return nil, fmt.Errorf("%s(): cannot compare %s and %s", name, typeName(a), typeName(best))
+			}
+			if (name == "min" && c < 0) || (name == "max" && c > 0) {
+				best = a
+			}
+		}
+		return best, nil
+	}
+	return nil, fmt.Errorf("unsupported function %s()", name)
+}
+
+func (e *evaluator) method(recv any, name string, args []any) (any, error) {
+	str := func(i int) string {
+		if i < len(args) {
+			return toString(args[i])
+		}
+		return ""
+	}
+
+	// Methods available on every value.
+	switch name {
+	case "toString":
+		return toString(recv), nil
+	case "isTruthy":
+		return truthy(recv), nil
+	case "isEmpty":
+		switch v := recv.(type) {
+		case nil:
+			return true, nil
+		case string:
+			return v == "", nil
+		case []any:
+			return len(v) == 0, nil
+		case map[string]any:
+			return len(v) == 0, nil
+		}
+		return false, nil
+	case "isType":
+		return typeName(recv) == str(0), nil
+	}
+
+	switch v := recv.(type) {
+	case fileRef:
+		return e.fileMethod(v.note, name, args)
+
+	case string:
+		switch name {
+		case "contains":
+			return strings.Contains(v, str(0)), nil
+		case "containsAll":
+			for i := range args {
+				if !strings.Contains(v, str(i)) {
+					return false, nil
+				}
+			}
+			return true, nil
+		case "containsAny":
+			for i := range args {
+				if strings.Contains(v, str(i)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "startsWith":
+			return strings.HasPrefix(v, str(0)), nil
+		case "endsWith":
+			return strings.HasSuffix(v, str(0)), nil
+		case "lower":
+			return strings.ToLower(v), nil
+		case "upper":
+			return strings.ToUpper(v), nil
+		case "title":
+			words := strings.Fields(v)
+			for i, w := range words {
+				rs := []rune(w)
+				words[i] = strings.ToUpper(string(rs[0])) + string(rs[1:])
+			}
+			return strings.Join(words, " "), nil
+		case "trim":
+			return strings.TrimSpace(v), nil
+		case "replace":
+			return strings.ReplaceAll(v, str(0), str(1)), nil
+		case "split":
+			parts := strings.Split(v, str(0))
+			out := make([]any, len(parts))
+			for i, p := range parts {
+				out[i] = p
+			}
+			return out, nil
+		case "slice":
+			rs := []rune(v)
+			start, end, err := sliceBounds(args, len(rs))
+			if err != nil {
+				return nil, err
+			}
+			return string(rs[start:end]), nil
+		case "reverse":
+			rs := []rune(v)
+			slices.Reverse(rs)
+			return string(rs), nil
+		}
+
+	case []any:
+		switch name {
+		case "contains":
+			for _, item := range v {
+				if len(args) > 0 && e.equal(item, args[0]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "containsAll", "containsAny":
+			found := 0
+			for _, a := range args {
+				if slices.ContainsFunc(v, func(item any) bool { return e.equal(item, a) }) {
+					found++
+				}
+			}
+			if name == "containsAll" {
+				return found == len(args), nil
+			}
+			return found > 0, nil
+		case "join":
+			parts := make([]string, len(v))
+			for i, item := range v {
+				parts[i] = toString(item)
+			}
+			return strings.Join(parts, str(0)), nil
+		case "unique":
+			var out []any
+			for _, item := range v {
+				if !slices.ContainsFunc(out, func(o any) bool { return e.equal(o, item) }) {
+					out = append(out, item)
+				}
+			}
+			return out, nil
+		case "sort":
+			out := append([]any(nil), v...)
+			sort.SliceStable(out, func(i, j int) bool { return order(out[i], out[j]) < 0 })
+			return out, nil
+		case "reverse":
+			out := append([]any(nil), v...)
+			slices.Reverse(out)
+			return out, nil
+		case "flat":
+			var out []any
+			for _, item := range v {
+				if l, ok := item.([]any); ok {
+					out = append(out, l...)
+				} else {
+					out = append(out, item)
+				}
+			}
+			return out, nil
+		case "slice":
+			start, end, err := sliceBounds(args, len(v))
+			if err != nil {
+				return nil, err
+			}
+			return v[start:end], nil
+		}
+
+	case float64:
+		switch name {
+		case "abs":
+			return math.Abs(v), nil
+		case "ceil":
+			return math.Ceil(v), nil
+		case "floor":
+			return math.Floor(v), nil
+		case "round":
+			digits := 0.0
+			if len(args) > 0 {
+				digits, _ = args[0].(float64)
+			}
+			p := math.Pow(10, digits)
+			return math.Round(v*p) / p, nil
+		case "toFixed":
+			digits := 0.0
+			if len(args) > 0 {
+				digits, _ = args[0].(float64)
+			}
+			return strconv.FormatFloat(v, 'f', int(digits), 64), nil
+		}
+
+	case time.Time:
+		switch name {
+		case "format":
+			return formatMoment(v, str(0)), nil
+		case "date":
+			y, m, d := v.Date()
+			return time.Date(y, m, d, 0, 0, 0, 0, v.Location()), nil
+		case "time":
+			return v.Format("15:04:05"), nil
+		}
+
+	case linkValue:
+		if name == "asLink" {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s has no function %s()", typeName(recv), name)
+}
+
+func (e *evaluator) fileMethod(note *types.MetaMarkdown, name string, args []any) (any, error) {
+	switch name {
+	case "hasTag":
+		for _, a := range args {
+			want := strings.TrimPrefix(toString(a), "#")
+			for _, tag := range note.Tags {
+				// A tag also matches its nested tags, like in Obsidian.
+				if tag == want || strings.HasPrefix(tag, want+"/") {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+
+	case "inFolder":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("inFolder() takes one folder")
+		}
+		folder := strings.Trim(toString(args[0]), "/")
+		rel := filepath.ToSlash(note.RelativePath)
+		return folder == "" || strings.HasPrefix(rel, folder+"/"), nil
+
+	case "hasLink":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("hasLink() takes one note")
+		}
+		url, ok := e.linkURL(args[0])
+		if !ok {
+			return false, nil
+		}
+		for _, l := range note.OutgoingLinks {
+			if stripFragment(l.URL) == url {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "hasProperty":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("hasProperty() takes one property")
+		}
+		_, ok := properties(note)[toString(args[0])]
+		return ok, nil
+
+	case "asLink":
+		l := linkValue{target: strings.TrimSuffix(filepath.ToSlash(note.RelativePath), ".md"), text: note.Title}
+		if len(args) > 0 {
+			l.text = toString(args[0])
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("file has no function %s()", name)
+}
+
+// linkURL returns the page URL a file, link or note name refers to.
+func (e *evaluator) linkURL(v any) (string, bool) {
+	switch v := v.(type) {
+	case fileRef:
+		return v.note.Link, true
+	case linkValue:
+		return e.resolve(v.target)
+	case string:
+		target := strings.TrimSuffix(strings.TrimPrefix(v, "[["), "]]")
+		return e.resolve(target)
+	}
+	return "", false
+}
+
+func (e *evaluator) resolve(target string) (string, bool) {
+	if k := strings.IndexByte(target, '|'); k >= 0 {
+		target = target[:k]
+	}
+	if e.env.Resolve == nil {
+		return "", false
+	}
+	url, ok := e.env.Resolve(strings.TrimSpace(target))
+	return stripFragment(url), ok
+}
+
+func stripFragment(url string) string {
+	if k := strings.IndexByte(url, '#'); k >= 0 {
+		return url[:k]
+	}
+	return url
+}
+
+func sliceBounds(args []any, n int) (int, int, error) {
+	start, end := 0, n
+	if len(args) > 0 {
+		f, ok := args[0].(float64)
+		if !ok {
+			return 0, 0, fmt.Errorf("slice() takes numbers")
+		}
+		start = int(f)
+	}
+	if len(args) > 1 {
+		f, ok := args[1].(float64)
+		if !ok {
+			return 0, 0, fmt.Errorf("slice() takes numbers")
+		}
+		end = int(f)
+	}
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	start = max(0, min(start, n))
+	end = max(start, min(end, n))
+	return start, end, nil
+}
+
+func (e *evaluator) equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch av := a.(type) {
+	case fileRef:
+		if bv, ok := b.(fileRef); ok {
+			return av.note.Path == bv.note.Path
+		}
+		url, ok := e.linkURL(b)
+		return ok && url == av.note.Link
+	case linkValue:
+		if _, ok := b.(fileRef); ok {
+			return e.equal(b, a)
+		}
+		au, aok := e.linkURL(av)
+		bu, bok := e.linkURL(b)
+		return aok && bok && au == bu
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !e.equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if _, ok := b.(fileRef); ok {
+		return e.equal(b, a)
+	}
+	if _, ok := b.(linkValue); ok {
+		return e.equal(b, a)
+	}
+
+	c, ok := compare(a, b)
+	return ok && c == 0
+}
+
+// compare orders two numbers, strings, booleans or dates. Strings compared to
+// dates are parsed as dates.
+func compare(a, b any) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return cmpFloat(av, bv), true
+		}
+	case string:
+		switch bv := b.(type) {
+		case string:
+			return strings.Compare(av, bv), true
+		case time.Time:
+			if at, ok := toTime(av); ok {
+				return at.Compare(bv), true
+			}
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			if av == bv {
+				return 0, true
+			}
+			if !av {
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if bt, ok := toTime(b); ok {
+			return av.Compare(bt), true
+		}
+	}
+	return 0, false
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// order sorts any two values: empty values last, then by compare, falling
+// back to their text, case-insensitively.
+func order(a, b any) int {
+	aEmpty, bEmpty := isEmpty(a), isEmpty(b)
+	switch {
+	case aEmpty && bEmpty:
+		return 0
+	case aEmpty:
+		return 1
+	case bEmpty:
+		return -1
+	}
+
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			return strings.Compare(strings.ToLower(as), strings.ToLower(bs))
+		}
+	}
+	if c, ok := compare(a, b); ok {
+		return c
+	}
+	return strings.Compare(strings.ToLower(toString(a)), strings.ToLower(toString(b)))
+}
+
+func isEmpty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	case time.Time:
+		return !v.IsZero()
+	}
+	return true
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case time.Time:
+		return "date"
+	case fileRef:
+		return "file"
+	case linkValue:
+		return "link"
+	case map[string]any:
+		return "object"
+	case *row:
+		return "formula"
+	case thisRef:
+		return "this"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// toString returns the plain text of a value.
+func toString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if h, m, s := v.Clock(); h == 0 && m == 0 && s == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04")
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = toString(item)
+		}
+		return strings.Join(parts, ", ")
+	case fileRef:
+		return v.note.Title
+	case linkValue:
+		if v.text != "" {
+			return v.text
+		}
+		return v.target
+	}
+	return fmt.Sprint(v)
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+var durationUnits = map[string]string{
+	"y": "y", "year": "y", "years": "y",
+	"M": "M", "month": "M", "months": "M",
+	"w": "w", "week": "w", "weeks": "w",
+	"d": "d", "day": "d", "days": "d",
+	"h": "h", "hour": "h", "hours": "h",
+	"m": "m", "minute": "m", "minutes": "m",
+	"s": "s", "second": "s", "seconds": "s",
+}
+
+// addDuration adds a duration like "1d", "2 weeks" or "3M" to t.
+func addDuration(t time.Time, d string, subtract bool) (time.Time, error) {
+	s := strings.TrimSpace(d)
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '-') {
+		i++
+	}
+	n, err := strconv.Atoi(s[:i])
+	unit, ok := durationUnits[strings.TrimSpace(s[i:])]
+	if !ok {
+		unit, ok = durationUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	}
+	if err != nil || !ok {
+		return time.Time{}, fmt.Errorf("invalid duration %q", d)
+	}
+	if subtract {
+		n = -n
+	}
+
+	switch unit {
+	case "y":
+		return t.AddDate(n, 0, 0), nil
+	case "M":
+		return t.AddDate(0, n, 0), nil
+	case "w":
+		return t.AddDate(0, 0, 7*n), nil
+	case "d":
+		return t.AddDate(0, 0, n), nil
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case "m":
+		return t.Add(time.Duration(n) * time.Minute), nil
+	}
+	return t.Add(time.Duration(n) * time.Second), nil
+}
+
+// momentTokens maps the Moment.js tokens used by Obsidian to Go layouts,
+// longest first.
+var momentTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"dddd", "Monday"}, {"ddd", "Mon"},
+	{"DD", "02"}, {"D", "2"},
+	{"HH", "15"}, {"hh", "03"}, {"h", "3"},
+	{"mm", "04"}, {"ss", "05"},
+	{"A", "PM"}, {"a", "pm"},
+}
+
+func formatMoment(t time.Time, format string) string {
+	if format == "" {
+		return toString(t)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		matched := false
+		for _, tok := range momentTokens {
+			if strings.HasPrefix(format[i:], tok.token) {
+				b.WriteString(t.Format(tok.layout))
+				i += len(tok.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,308 @@
+package bases
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expressions are the formulas and filters of a base, such as
+// `file.hasTag("project") && status != "done"`.
+type expr interface{}
+
+type literal struct{ value any }
+
+type ident struct{ name string }
+
+type member struct {
+	x    expr
+	name string
+}
+
+type index struct{ x, i expr }
+
+type call struct {
+	fn   expr
+	args []expr
+}
+
+type unary struct {
+	op string
+	x  expr
+}
+
+type binary struct {
+	op   string
+	x, y expr
+}
+
+type listLit struct{ items []expr }
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+var operators = []string{"&&", "||", "==", "!=", ">=", "<=", ">", "<", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ",", "."}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(rs) && rs[j] != r; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) {
+					j++
+				}
+				b.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			toks = append(toks, token{kind: tokString, text: b.String(), pos: i})
+			i = j + 1
+
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(string(rs[i:j]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", string(rs[i:j]))
+			}
+			toks = append(toks, token{kind: tokNumber, num: n, text: string(rs[i:j]), pos: i})
+			i = j
+
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '$') {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(rs[i:j]), pos: i})
+			i = j
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(string(rs[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", string(r), i)
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += len([]rune(op))
+		}
+	}
+
+	return append(toks, token{kind: tokEOF, pos: len(rs)}), nil
+}
+
+var binaryPrec = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, ">": 4, "<=": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+const unaryPrec = 7
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// parseExpr parses a base expression.
+func parseExpr(src string) (expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+	x, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return x, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(op string) error {
+	if t := p.next(); t.kind != tokOp || t.text != op {
+		return fmt.Errorf("expected %q at %d", op, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) parse(prec int) (expr, error) {
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind != tokOp {
+			return left, nil
+		}
+
+		switch t.text {
+		case ".":
+			p.next()
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, fmt.Errorf("expected a name after \".\" at %d", name.pos)
+			}
+			left = member{x: left, name: name.text}
+			continue
+
+		case "(":
+			p.next()
+			args, err := p.list(")")
+			if err != nil {
+				return nil, err
+			}
+			left = call{fn: left, args: args}
+			continue
+
+		case "[":
+			p.next()
+			i, err := p.parse(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			left = index{x: left, i: i}
+			continue
+		}
+
+		bp := binaryPrec[t.text]
+		if bp == 0 || bp <= prec {
+			return left, nil
+		}
+		p.next()
+
+		right, err := p.parse(bp)
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: t.text, x: left, y: right}
+	}
+}
+
+func (p *parser) prefix() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return literal{t.num}, nil
+
+	case tokString:
+		return literal{t.text}, nil
+
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		return ident{t.text}, nil
+
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.parse(0)
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+
+		case "[":
+			items, err := p.list("]")
+			if err != nil {
+				return nil, err
+			}
+			return listLit{items}, nil
+
+		case "!", "-":
+			x, err := p.parse(unaryPrec)
+			if err != nil {
+				return nil, err
+			}
+			return unary{op: t.text, x: x}, nil
+		}
+	}
+
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+// list parses comma separated expressions up to the closing token.
+func (p *parser) list(closing string) ([]expr, error) {
+	var items []expr
+	if t := p.peek(); t.kind == tokOp && t.text == closing {
+		p.next()
+		return items, nil
+	}
+
+	for {
+		x, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, x)
+
+		t := p.next()
+		if t.kind == tokOp && t.text == closing {
+			return items, nil
+		}
+		if t.kind != tokOp || t.text != "," {
+			return nil, fmt.Errorf("expected \",\" or %q at %d", closing, t.pos)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package bases
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var wikilinkValueReg = regexp.MustCompile(`^!?\[\[([^\[\]]+)\]\]$`)
+
+// Render evaluates the view called view, or the first view when view is
+// empty, and returns it as HTML. Filters, formulas and properties that cannot
+// be evaluated are skipped and reported in the returned warnings.
+func (b *Base) Render(view string, env Env) (string, []string) {
+	v, ok := b.view(view)
+	if !ok {
+		return "", []string{fmt.Sprintf("view %q does not exist", view)}
+	}
+
+	e := &evaluator{base: b, env: env}
+	if env.This != nil {
+		e.this = newRow(env.This)
+	}
+	w := &warnings{seen: make(map[string]bool)}
+
+	var rows []*row
+	for i := range env.Notes {
+		r := newRow(&env.Notes[i])
+		ok, err := e.match(&b.Filters, r)
+		if err == nil && ok {
+			ok, err = e.match(&v.Filters, r)
+		}
+		if err != nil {
+			return "", []string{fmt.Sprintf("view %q skipped: %v", view, err)}
+		}
+		if ok {
+			rows = append(rows, r)
+		}
+	}
+
+	columns := v.Order
+	if len(columns) == 0 {
+		columns = []string{"file.name"}
+	}
+
+	value := func(r *row, prop string) any {
+		x := b.property(prop)
+		if x == nil {
+			return nil
+		}
+		val, err := e.eval(x, r)
+		if err != nil {
+			w.add(fmt.Sprintf("property %q: %v", prop, err))
+			return nil
+		}
+		return val
+	}
+
+	if len(v.Sort) > 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			for _, s := range v.Sort {
+				c := order(value(rows[i], s.Property), value(rows[j], s.Property))
+				if s.desc() {
+					c = -c
+				}
+				if c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		})
+	}
+
+	if v.Limit > 0 && len(rows) > v.Limit {
+		rows = rows[:v.Limit]
+	}
+
+	type group struct {
+		key  any
+		rows []*row
+	}
+	groups := []*group{{rows: rows}}
+	if v.GroupBy != nil {
+		groups = nil
+		byKey := make(map[string]*group)
+		for _, r := range rows {
+			key := value(r, v.GroupBy.Property)
+			text := toString(key)
+			g, ok := byKey[text]
+			if !ok {
+				g = &group{key: key}
+				byKey[text] = g
+				groups = append(groups, g)
+			}
+			g.rows = append(g.rows, r)
+		}
+		sort.SliceStable(groups, func(i, j int) bool {
+			c := order(groups[i].key, groups[j].key)
+			if v.GroupBy.desc() {
+				c = -c
+			}
+			return c < 0
+		})
+	}
+
+	typ := v.Type
+	switch typ {
+	case "table", "cards", "list":
+	case "":
+		typ = "table"
+	default:
+		w.add(fmt.Sprintf("view type %q is not supported, showing a table", v.Type))
+		typ = "table"
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, `<div class="base-view base-%s">`+"\n", typ)
+	if v.Name != "" {
+		fmt.Fprintf(&out, `<div class="base-view-name">%s</div>`+"\n", html.EscapeString(v.Name))
+	}
+	if len(rows) == 0 {
+		out.WriteString(`<p class="base-empty">No notes match this view.</p>` + "\n")
+	}
+
+	for _, g := range groups {
+		if len(g.rows) == 0 {
+			continue
+		}
+		if v.GroupBy != nil {
+			label := toString(g.key)
+			if label == "" {
+				label = "—"
+			}
+			fmt.Fprintf(&out, `<div class="base-group">%s</div>`+"\n", html.EscapeString(label))
+		}
+
+		switch typ {
+		case "table":
+			out.WriteString("<table>\n<thead>\n<tr>")
+			for _, c := range columns {
+				fmt.Fprintf(&out, "<th>%s</th>", html.EscapeString(b.label(c)))
+			}
+			out.WriteString("</tr>\n</thead>\n<tbody>\n")
+			for _, r := range g.rows {
+				out.WriteString("<tr>")
+				for _, c := range columns {
+					fmt.Fprintf(&out, "<td>%s</td>", e.cell(r, c, value(r, c)))
+				}
+				out.WriteString("</tr>\n")
+			}
+			out.WriteString("</tbody>\n</table>\n")
+
+		case "cards":
+			out.WriteString(`<ul class="base-cards">` + "\n")
+			for _, r := range g.rows {
+				out.WriteString(`<li class="base-card">`)
+				if v.Image != "" {
+					if src := e.imageURL(value(r, v.Image)); src != "" {
+						fmt.Fprintf(&out, `<img class="base-card-image" src="%s" alt="" loading="lazy" />`, html.EscapeString(src))
+					}
+				}
+				fmt.Fprintf(&out, `<a class="base-card-title" href="%s">%s</a>`, html.EscapeString(r.note.Link), html.EscapeString(r.note.Title))
+				out.WriteString(e.properties(r, columns, value))
+				out.WriteString("</li>\n")
+			}
+			out.WriteString("</ul>\n")
+
+		case "list":
+			out.WriteString(`<ul class="base-list">` + "\n")
+			for _, r := range g.rows {
+				fmt.Fprintf(&out, `<li><a href="%s">%s</a>`, html.EscapeString(r.note.Link), html.EscapeString(r.note.Title))
+				out.WriteString(e.properties(r, columns, value))
+				out.WriteString("</li>\n")
+			}
+			out.WriteString("</ul>\n")
+		}
+	}
+
+	out.WriteString("</div>\n")
+	return out.String(), w.list
+}
+
+type warnings struct {
+	seen map[string]bool
+	list []string
+}
+
+func (w *warnings) add(msg string) {
+	if !w.seen[msg] {
+		w.seen[msg] = true
+		w.list = append(w.list, msg)
+	}
+}
+
+// match reports whether r passes f. A filter that cannot be evaluated is an
+// error, as letting every note through or hiding them all would be wrong.
+func (e *evaluator) match(f *Filter, r *row) (bool, error) {
+	if f.empty() {
+		return true, nil
+	}
+
+	if f.Expr != "" {
+		if f.compiled == nil {
+			return false, fmt.Errorf("filter %q is not supported", f.Expr)
+		}
+		v, err := e.eval(f.compiled, r)
+		if err != nil {
+			return false, fmt.Errorf("filter %q: %w", f.Expr, err)
+		}
+		return truthy(v), nil
+	}
+
+	for i := range f.And {
+		if ok, err := e.match(&f.And[i], r); err != nil || !ok {
+			return false, err
+		}
+	}
+	if len(f.Or) > 0 {
+		matched := false
+		for i := range f.Or {
+			ok, err := e.match(&f.Or[i], r)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	for i := range f.Not {
+		if f.Not[i].empty() {
+			continue
+		}
+		if ok, err := e.match(&f.Not[i], r); err != nil || ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// property returns the expression of a column such as "status",
+// "note.status", "file.name" or "formula.age".
+func (b *Base) property(prop string) expr {
+	if x, ok := b.columns[prop]; ok {
+		return x
+	}
+	x, err := parseExpr(prop)
+	if err != nil || check(x) != nil {
+		return nil
+	}
+	return x
+}
+
+// label returns the column header of a property. "status" and
+// "note.status" are the same property, whichever way each is written.
+func (b *Base) label(prop string) string {
+	if p, ok := b.Properties[prop]; ok && p.DisplayName != "" {
+		return p.DisplayName
+	}
+	if p, ok := b.Properties[otherPropertyKey(prop)]; ok && p.DisplayName != "" {
+		return p.DisplayName
+	}
+	if prop == "file.name" || prop == "file.basename" {
+		return "Name"
+	}
+	prop = strings.TrimPrefix(prop, "note.")
+	return strings.TrimPrefix(prop, "formula.")
+}
+
+// otherPropertyKey returns the other spelling of a note property, "status"
+// for "note.status" and the other way round.
+func otherPropertyKey(prop string) string {
+	if name, ok := strings.CutPrefix(prop, "note."); ok {
+		return name
+	}
+	if !strings.Contains(prop, ".") {
+		return "note." + prop
+	}
+	return prop
+}
+
+// properties renders the columns of a card or list item, leaving out the note
+// name already shown as its title.
+func (e *evaluator) properties(r *row, columns []string, value func(*row, string) any) string {
+	var b strings.Builder
+	for _, c := range columns {
+		if c == "file.name" || c == "file.basename" {
+			continue
+		}
+		val := value(r, c)
+		if isEmpty(val) {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString(`<dl class="base-properties">`)
+		}
+		fmt.Fprintf(&b, "<dt>%s</dt><dd>%s</dd>", html.EscapeString(e.base.label(c)), e.format(val))
+	}
+	if b.Len() > 0 {
+		b.WriteString("</dl>")
+	}
+	return b.String()
+}
+
+// cell renders a table cell. The note name links to the note and shows its
+// title.
+func (e *evaluator) cell(r *row, prop string, v any) string {
+	if prop == "file.name" || prop == "file.basename" {
+		return e.format(fileRef{r.note})
+	}
+	return e.format(v)
+}
+
+// format renders a value as HTML. Files and "[[Note]]" properties become
+// links.
+func (e *evaluator) format(v any) string {
+	switch v := v.(type) {
+	case fileRef:
+		return anchor(v.note.Link, v.note.Title)
+	case linkValue:
+		if url, ok := e.resolve(v.target); ok {
+			return anchor(url, toString(v))
+		}
+		return html.EscapeString(toString(v))
+	case string:
+		if m := wikilinkValueReg.FindStringSubmatch(v); m != nil {
+			target, text := m[1], m[1]
+			if k := strings.IndexByte(m[1], '|'); k >= 0 {
+				target, text = m[1][:k], m[1][k+1:]
+			}
+			return e.format(linkValue{target: target, text: text})
+		}
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = e.format(item)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return html.EscapeString(toString(v))
+}
+
+// imageURL returns the source of a card image: a URL, or a "[[image.png]]"
+// wikilink resolved like any other.
+func (e *evaluator) imageURL(v any) string {
+	s := toString(v)
+	if s == "" {
+		return ""
+	}
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		return s
+	}
+	if m := wikilinkValueReg.FindStringSubmatch(s); m != nil {
+		s = m[1]
+	}
+	url, _ := e.resolve(s)
+	return url
+}
+
+func anchor(href, text string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(href), html.EscapeString(text))
+}
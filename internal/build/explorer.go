@@ -9,17 +9,58 @@ import (
 	"geode/internal/utils"
 )
 
-func RenderExplorer(tree *types.FileTree) string {
+// RenderExplorer renders the notes of tree written in lang; an empty lang
+// renders every note. When every note of lang lives in its language folder,
+// such as content/id/, that folder is shown as the root of the explorer.
+func RenderExplorer(tree *types.FileTree, lang string) string {
 	sortTree(tree)
+
+	root, rootKey := tree, ""
+	if folder := languageRoot(tree, lang); folder != nil {
+		root, rootKey = folder, lang
+	}
+
 	var b strings.Builder
 	b.WriteString(`<ul class="file-explorer">`)
-	for _, child := range tree.Children {
-		renderNode(&b, child, "")
+	for _, child := range root.Children {
+		renderNode(&b, child, rootKey, lang)
 	}
 	b.WriteString(`</ul>`)
 	return b.String()
 }
 
+func languageRoot(tree *types.FileTree, lang string) *types.FileTree {
+	if lang == "" {
+		return nil
+	}
+
+	var folder *types.FileTree
+	for _, child := range tree.Children {
+		if child.Name == lang && child.Path == "" && child.Link == "" {
+			folder = child
+		} else if hasLang(child, lang) {
+			return nil
+		}
+	}
+	return folder
+}
+
+// hasLang reports whether node is or contains a note written in lang.
+func hasLang(node *types.FileTree, lang string) bool {
+	if lang == "" {
+		return true
+	}
+	if node.Link != "" || node.Path != "" {
+		return node.Lang == lang
+	}
+	for _, child := range node.Children {
+		if hasLang(child, lang) {
+			return true
+		}
+	}
+	return false
+}
+
 func normalizeExplorerLink(raw string) string {
 	url := strings.TrimPrefix(raw, "/")
 	url = strings.TrimSuffix(url, "/")
@@ -39,7 +80,11 @@ func normalizeExplorerLink(raw string) string {
 	return "/" + url
 }
 
-func renderNode(b *strings.Builder, node *types.FileTree, parentKey string) {
+func renderNode(b *strings.Builder, node *types.FileTree, parentKey, lang string) {
+	if !hasLang(node, lang) {
+		return
+	}
+
 	isFile := node.Link != "" || node.Path != ""
 
 	key := node.Name
@@ -58,8 +103,7 @@ func renderNode(b *strings.Builder, node *types.FileTree, parentKey string) {
 		if node.Link != "" {
 			link = normalizeExplorerLink(node.Link)
 		} else {
-			url := utils.PathToSlug(node.Path)
-			url = strings.TrimSuffix(url, ".md")
+			url := utils.PageSlug(node.Path)
 			link = normalizeExplorerLink(url)
 		}
 
@@ -73,15 +117,15 @@ func renderNode(b *strings.Builder, node *types.FileTree, parentKey string) {
 	} else {
 		b.WriteString(`<span class="folder">` +
 			FolderChevronIcon +
-			`<span class="folder-name">` +
+			`<a class="folder-name" href="` + html.EscapeString(FolderURL(key)) + `">` +
 			html.EscapeString(node.Name) +
-			`</span></span>`)
+			`</a></span>`)
 	}
 
 	if len(node.Children) > 0 {
 		b.WriteString("<ul>")
 		for _, c := range node.Children {
-			renderNode(b, c, key)
+			renderNode(b, c, key, lang)
 		}
 		b.WriteString("</ul>")
 	}
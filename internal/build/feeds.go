@@ -0,0 +1,504 @@
+package build
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"geode/internal/config"
+	"geode/internal/types"
+	"geode/internal/utils"
+)
+
+// Feed file names. The site, every language and every folder get feed.xml,
+// atom.xml and feed.json; tag feeds live next to the tag page as <tag>.xml,
+// <tag>.atom.xml and <tag>.json.
+const (
+	rssFile  = "feed.xml"
+	atomFile = "atom.xml"
+	jsonFile = "feed.json"
+)
+
+// FeedLink describes a feed for a <link rel="alternate"> element.
+type FeedLink struct {
+	Type  string
+	Title string
+	URL   string
+}
+
+type feedItem struct {
+	Title     string
+	URL       string
+	Published time.Time
+	Updated   time.Time
+	Summary   string
+	HTML      string
+	Tags      []string
+}
+
+type feed struct {
+	Title   string
+	HomeURL string
+	Lang    string
+	Items   []feedItem
+
+	rssURL, atomURL, jsonURL string
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// pageDate returns the time stored under key in the frontmatter.
+func pageDate(front map[string]any, key string) (time.Time, bool) {
+	switch v := front[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// SiteFeeds returns the site-wide feeds of a language for
+// <link rel="alternate"> elements.
+func SiteFeeds(cfg *config.Config, lang string) []FeedLink {
+	return feedLinks(feedTitle(cfg, lang), cfg.LangPrefix(lang)+"/")
+}
+
+// TagFeeds returns the feeds of a tag in the listings of lang, or nothing
+// when tag feeds are off.
+func TagFeeds(cfg *config.Config, lang, tag string) []FeedLink {
+	if !cfg.Feeds.Tags {
+		return nil
+	}
+	base := TagURL(cfg, lang, tag)
+	title := feedTitle(cfg, lang) + " - #" + tag
+	return []FeedLink{
+		{Type: "application/rss+xml", Title: title, URL: base + ".xml"},
+		{Type: "application/atom+xml", Title: title, URL: base + ".atom.xml"},
+		{Type: "application/feed+json", Title: title, URL: base + ".json"},
+	}
+}
+
+// FolderFeeds returns the feeds of a folder, or nothing when folder feeds
+// are off.
+func FolderFeeds(cfg *config.Config, folder string) []FeedLink {
+	if !cfg.Feeds.Folders {
+		return nil
+	}
+	return feedLinks(cfg.Site.Name+" - "+folder, "/"+utils.PathToSlug(folder)+"/")
+}
+
+// feedTitle is the site name, followed by the language name for the feeds of
+// the other languages.
+func feedTitle(cfg *config.Config, lang string) string {
+	if cfg.LangPrefix(lang) == "" {
+		return cfg.Site.Name
+	}
+	if l, ok := cfg.Language(lang); ok {
+		return cfg.Site.Name + " (" + l.Name + ")"
+	}
+	return cfg.Site.Name
+}
+
+func feedLinks(title, dir string) []FeedLink {
+	return []FeedLink{
+		{Type: "application/rss+xml", Title: title, URL: dir + rssFile},
+		{Type: "application/atom+xml", Title: title, URL: dir + atomFile},
+		{Type: "application/feed+json", Title: title, URL: dir + jsonFile},
+	}
+}
+
+// BuildFeeds writes RSS 2.0, Atom 1.0 and JSON Feed 1.1 feeds of the notes
+// that have a created or modified date, newest first, for every language of
+// the site. With feeds.tags and feeds.folders every tag and folder gets its
+// own feeds too.
+func BuildFeeds(cfg *config.Config, pages []types.MetaMarkdown) error {
+	outputDir := cfg.Build.Output
+	baseURL := cfg.SiteURL()
+
+	type langTag struct{ lang, tag string }
+
+	byLang := make(map[string][]feedItem)
+	byTag := make(map[langTag][]feedItem)
+	byFolder := make(map[string][]feedItem)
+
+	for _, page := range pages {
+		if page.Link == "" {
+			continue
+		}
+
+		published, hasCreated := pageDate(page.Frontmatter, "created")
+		updated, hasModified := pageDate(page.Frontmatter, "modified")
+		if !hasCreated && !hasModified {
+			continue
+		}
+		if !hasCreated {
+			published = updated
+		}
+		if !hasModified {
+			updated = published
+		}
+
+		url := strings.TrimSuffix(page.Link, "/index")
+		if url == "" {
+			url = "/"
+		}
+
+		item := feedItem{
+			Title:     page.Title,
+			URL:       baseURL + trailingSlash(cfg, url),
+			Published: published,
+			Updated:   updated,
+			Summary:   page.Description,
+			Tags:      page.Tags,
+		}
+		if cfg.Feeds.FullContent {
+			// Feed readers show content away from the site, so links and
+			// images get absolute URLs.
+			item.HTML = string(rewriteURLs(cfg, []byte(page.HTML), baseURL))
+		}
+
+		byLang[page.Lang] = append(byLang[page.Lang], item)
+		for _, tag := range page.Tags {
+			key := langTag{page.Lang, tag}
+			byTag[key] = append(byTag[key], item)
+		}
+
+		// Notes count towards the feeds of every folder they are in. The
+		// language folders already have the feeds of their language.
+		for dir := path.Dir(filepath.ToSlash(page.RelativePath)); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if prefix := cfg.LangPrefix(folderLang(cfg, dir)); prefix != "" && FolderURL(dir) == prefix {
+				continue
+			}
+			byFolder[dir] = append(byFolder[dir], item)
+		}
+	}
+
+	var feeds []feed
+	for _, lang := range cfg.LanguageCodes() {
+		links := SiteFeeds(cfg, lang)
+		feeds = append(feeds, feed{
+			Title:   links[0].Title,
+			HomeURL: baseURL + cfg.LangPrefix(lang) + "/",
+			Lang:    lang,
+			Items:   byLang[lang],
+			rssURL:  links[0].URL,
+			atomURL: links[1].URL,
+			jsonURL: links[2].URL,
+		})
+	}
+
+	if cfg.Feeds.Tags {
+		for key, tagItems := range byTag {
+			links := TagFeeds(cfg, key.lang, key.tag)
+			feeds = append(feeds, feed{
+				Title:   links[0].Title,
+				HomeURL: baseURL + trailingSlash(cfg, TagURL(cfg, key.lang, key.tag)),
+				Lang:    key.lang,
+				Items:   tagItems,
+				rssURL:  links[0].URL,
+				atomURL: links[1].URL,
+				jsonURL: links[2].URL,
+			})
+		}
+	}
+
+	if cfg.Feeds.Folders {
+		for folder, folderItems := range byFolder {
+			links := FolderFeeds(cfg, folder)
+			feeds = append(feeds, feed{
+				Title:   links[0].Title,
+				HomeURL: baseURL + trailingSlash(cfg, FolderURL(folder)),
+				Lang:    folderLang(cfg, folder),
+				Items:   folderItems,
+				rssURL:  links[0].URL,
+				atomURL: links[1].URL,
+				jsonURL: links[2].URL,
+			})
+		}
+	}
+
+	for _, f := range feeds {
+		sort.SliceStable(f.Items, func(i, j int) bool {
+			if !f.Items[i].Published.Equal(f.Items[j].Published) {
+				return f.Items[i].Published.After(f.Items[j].Published)
+			}
+			return f.Items[i].Title < f.Items[j].Title
+		})
+		if cfg.Feeds.Limit > 0 && len(f.Items) > cfg.Feeds.Limit {
+			f.Items = f.Items[:cfg.Feeds.Limit]
+		}
+
+		outputs := []struct {
+			url    string
+			encode func(feed, string) ([]byte, error)
+		}{
+			{f.rssURL, rssFeed},
+			{f.atomURL, atomFeed},
+			{f.jsonURL, jsonFeed},
+		}
+		for _, out := range outputs {
+			data, err := out.encode(f, baseURL)
+			if err == nil {
+				err = writeFeed(outputDir, out.url, data)
+			}
+			if err != nil {
+				return fmt.Errorf("write %s: %w", out.url, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// tagFeedPaths returns the output paths of the feeds of a tag in the
+// listings of lang.
+func tagFeedPaths(cfg *config.Config, lang, tag string) []string {
+	base := filepath.Join(cfg.Build.Output, filepath.FromSlash(strings.TrimPrefix(TagURL(cfg, lang, tag), "/")))
+	return []string{base + ".xml", base + ".atom.xml", base + ".json"}
+}
+
+func writeFeed(outputDir, url string, data []byte) error {
+	outPath := filepath.Join(outputDir, filepath.FromSlash(strings.TrimPrefix(url, "/")))
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, data, 0o644)
+}
+
+func (f feed) updated() time.Time {
+	var latest time.Time
+	for _, item := range f.Items {
+		if item.Updated.After(latest) {
+			latest = item.Updated
+		}
+	}
+	return latest
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	AtomLink      rssSelf   `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+}
+
+func rssFeed(f feed, baseURL string) ([]byte, error) {
+	channel := rssChannel{
+		Title:       f.Title,
+		Link:        f.HomeURL,
+		Description: f.Title,
+		Language:    f.Lang,
+		AtomLink:    rssSelf{Href: baseURL + f.rssURL, Rel: "self", Type: "application/rss+xml"},
+	}
+	if updated := f.updated(); !updated.IsZero() {
+		channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+
+	for _, item := range f.Items {
+		description := item.Summary
+		if item.HTML != "" {
+			description = item.HTML
+		}
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        item.URL,
+			PubDate:     item.Published.Format(time.RFC1123Z),
+			Description: description,
+			Categories:  item.Tags,
+		})
+	}
+
+	return encodeXML(rssDocument{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: channel})
+}
+
+type atomDocument struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string      `xml:"xml:lang,attr,omitempty"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+func atomFeed(f feed, baseURL string) ([]byte, error) {
+	doc := atomDocument{
+		Title:   f.Title,
+		Lang:    f.Lang,
+		ID:      baseURL + f.atomURL,
+		Updated: f.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: baseURL + f.atomURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.HomeURL, Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	for _, item := range f.Items {
+		entry := atomEntry{
+			Title:     item.Title,
+			ID:        item.URL,
+			Link:      atomLink{Href: item.URL, Rel: "alternate", Type: "text/html"},
+			Published: item.Published.Format(time.RFC3339),
+			Updated:   item.Updated.Format(time.RFC3339),
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Value: item.Summary}
+		}
+		if item.HTML != "" {
+			entry.Content = &atomText{Type: "html", Value: item.HTML}
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return encodeXML(doc)
+}
+
+func encodeXML(v any) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+type jsonFeedDocument struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Language    string         `json:"language,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html,omitempty"`
+	ContentText   string   `json:"content_text,omitempty"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+func jsonFeed(f feed, baseURL string) ([]byte, error) {
+	doc := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     baseURL + f.jsonURL,
+		Language:    f.Lang,
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, item := range f.Items {
+		entry := jsonFeedItem{
+			ID:            item.URL,
+			URL:           item.URL,
+			Title:         item.Title,
+			Summary:       item.Summary,
+			DatePublished: item.Published.Format(time.RFC3339),
+			DateModified:  item.Updated.Format(time.RFC3339),
+			Tags:          item.Tags,
+		}
+		// Every JSON Feed item needs content_html or content_text.
+		if item.HTML != "" {
+			entry.ContentHTML = item.HTML
+		} else {
+			entry.ContentText = item.Summary
+		}
+		doc.Items = append(doc.Items, entry)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// renderFeedLinks renders <link rel="alternate"> elements for feeds.
+func renderFeedLinks(feeds []FeedLink) template.HTML {
+	links := make([]string, 0, len(feeds))
+	for _, f := range feeds {
+		links = append(links, fmt.Sprintf(`<link rel="alternate" type="%s" title="%s" href="%s" />`,
+			template.HTMLEscapeString(f.Type),
+			template.HTMLEscapeString(f.Title),
+			template.HTMLEscapeString(f.URL)))
+	}
+	return template.HTML(strings.Join(links, "\n    "))
+}
@@ -0,0 +1,246 @@
+package build
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"geode/internal/config"
+	"geode/internal/types"
+	"geode/internal/utils"
+)
+
+type FolderEntry struct {
+	Title       string
+	URL         string
+	Description string
+	Date        string
+	Tags        []TagLink
+}
+
+type FolderPageData struct {
+	Name       template.HTML
+	Suffix     template.HTML
+	Explorer   template.HTML
+	Socials    template.HTML
+	LiveReload bool
+	Lang       string
+	T          UIStrings
+
+	Title      string
+	Folder     string
+	Intro      template.HTML
+	Feeds      template.HTML
+	TotalItems int
+	Folders    []FolderEntry
+	Pages      []FolderEntry
+}
+
+// FolderURL returns the URL of the listing page of a folder.
+func FolderURL(folder string) string {
+	return "/" + utils.PathToSlug(folder)
+}
+
+// BuildFolderPages writes a listing page for every folder of the file tree
+// when the theme has a folder.html template. A folder note, Folder/Folder.md
+// or Folder/index.md, is shown above the listing. Folders whose URL belongs
+// to a note get no listing page, and neither do folders whose URL is taken by
+// a redirect or a generated page, which is logged.
+func BuildFolderPages(cfg *config.Config, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+	templatePath := filepath.Join("themes", cfg.Theme, "templates", "folder.html")
+	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
+		return nil
+	}
+
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return fmt.Errorf("parse folder template: %w", err)
+	}
+
+	byPath := make(map[string]types.MetaMarkdown, len(pages))
+	taken := make(map[string]bool, len(pages))
+	for _, p := range pages {
+		byPath[p.RelativePath] = p
+		if p.Link != "" {
+			taken[p.Link] = true
+		}
+	}
+	// Conflicting redirects are reported by BuildRedirects.
+	redirected := make(map[string]string)
+	if redirects, err := CollectRedirects(cfg, pages); err == nil {
+		for _, r := range redirects {
+			redirected[r.From] = r.Source
+		}
+	}
+
+	// Listings of every language share the explorers and strings.
+	explorers := make(map[string]template.HTML)
+	uiStrings := make(map[string]UIStrings)
+
+	var walk func(node *types.FileTree, folder string) error
+	walk = func(node *types.FileTree, folder string) error {
+		for _, child := range node.Children {
+			if child.Path != "" || child.Link != "" {
+				continue
+			}
+			childFolder := path.Join(folder, child.Name)
+			if err := walk(child, childFolder); err != nil {
+				return err
+			}
+
+			url := FolderURL(childFolder)
+			if taken[url] {
+				continue
+			}
+			if source, ok := redirected[url]; ok {
+				log.Printf("folder page of %s skipped: %s is a redirect declared in %s", childFolder, url, source)
+				continue
+			}
+			if isReservedURL(cfg, url) {
+				log.Printf("folder page of %s skipped: %s is a page generated by Geode", childFolder, url)
+				continue
+			}
+
+			lang := folderLang(cfg, childFolder)
+			if _, ok := uiStrings[lang]; !ok {
+				strs, err := LoadUIStrings(cfg, lang)
+				if err != nil {
+					return err
+				}
+				uiStrings[lang] = strs
+				explorers[lang] = template.HTML(RenderExplorer(fileTree, lang))
+			}
+
+			data := folderPage(cfg, child, childFolder, byPath)
+			data.Name = template.HTML(cfg.Site.Name)
+			data.Suffix = template.HTML(cfg.Site.Suffix)
+			data.Explorer = explorers[lang]
+			data.Socials = template.HTML(RenderSocials(cfg.Socials))
+			data.LiveReload = liveReload
+			data.Lang = lang
+			data.T = uiStrings[lang]
+
+			if err := writeHTML(cfg, tmpl, pageFile(cfg, url), data); err != nil {
+				return fmt.Errorf("write folder page %s: %w", childFolder, err)
+			}
+		}
+		return nil
+	}
+
+	return walk(fileTree, "")
+}
+
+func folderPage(cfg *config.Config, node *types.FileTree, folder string, byPath map[string]types.MetaMarkdown) FolderPageData {
+	data := FolderPageData{
+		Title:  node.Name,
+		Folder: folder,
+		Feeds:  renderFeedLinks(FolderFeeds(cfg, folder)),
+	}
+
+	var notes []types.MetaMarkdown
+	for _, child := range node.Children {
+		if child.Path == "" && child.Link == "" {
+			data.Folders = append(data.Folders, FolderEntry{
+				Title: child.Name,
+				URL:   FolderURL(path.Join(folder, child.Name)),
+			})
+			continue
+		}
+
+		page, ok := byPath[child.Path]
+		if !ok {
+			continue
+		}
+
+		name := strings.TrimSuffix(child.Name, ".md")
+		if data.Intro == "" && (name == node.Name || name == "index") {
+			// An index.md without a title would name the folder "index".
+			if title, _ := page.Frontmatter["title"].(string); strings.TrimSpace(title) != "" {
+				data.Title = page.Title
+			}
+			data.Intro = template.HTML(page.HTML)
+			continue
+		}
+		notes = append(notes, page)
+	}
+
+	sort.Slice(data.Folders, func(i, j int) bool {
+		return strings.ToLower(data.Folders[i].Title) < strings.ToLower(data.Folders[j].Title)
+	})
+	sortFolderNotes(cfg, notes)
+
+	for _, p := range notes {
+		data.Pages = append(data.Pages, folderEntry(cfg, p))
+	}
+	data.TotalItems = len(data.Folders) + len(data.Pages)
+
+	return data
+}
+
+// sortFolderNotes orders notes by title, or newest first by their created or
+// modified date following folders.sort. Notes without a date go last.
+func sortFolderNotes(cfg *config.Config, notes []types.MetaMarkdown) {
+	byTitle := func(a, b types.MetaMarkdown) bool {
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	}
+
+	if cfg.Folders.Sort == config.FolderSortTitle {
+		sort.SliceStable(notes, func(i, j int) bool { return byTitle(notes[i], notes[j]) })
+		return
+	}
+
+	dates := make(map[string]time.Time, len(notes))
+	for _, p := range notes {
+		if t, ok := pageDate(p.Frontmatter, cfg.Folders.Sort); ok {
+			dates[p.Path] = t
+		}
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		a, b := dates[notes[i].Path], dates[notes[j].Path]
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return byTitle(notes[i], notes[j])
+	})
+}
+
+func folderEntry(cfg *config.Config, p types.MetaMarkdown) FolderEntry {
+	url := p.Link
+	if url == "" {
+		url = "/" + utils.PageSlug(p.RelativePath)
+	}
+
+	entry := FolderEntry{
+		Title:       p.Title,
+		URL:         url,
+		Description: p.Description,
+	}
+
+	// modified -> created, like the date shown on the note itself
+	if t, ok := pageDate(p.Frontmatter, "modified"); ok {
+		entry.Date = t.Format("2006-01-02")
+	} else if t, ok := pageDate(p.Frontmatter, "created"); ok {
+		entry.Date = t.Format("2006-01-02")
+	}
+
+	tags := make([]string, 0, len(p.Tags))
+	for _, raw := range p.Tags {
+		t := strings.TrimPrefix(strings.TrimSpace(raw), "#")
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+	sort.Strings(tags)
+	for _, t := range tags {
+		entry.Tags = append(entry.Tags, TagLink{Name: t, URL: TagURL(cfg, p.Lang, t)})
+	}
+
+	return entry
+}
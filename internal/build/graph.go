@@ -3,98 +3,257 @@ package build
 import (
 	"encoding/json"
 	"fmt"
+	"geode/internal/config"
 	"geode/internal/types"
 	"geode/internal/utils"
 	"html"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 )
 
-func BuildGraph(page types.MetaMarkdown) *types.GraphData {
-	nodes := make([]types.GraphNode, 0)
+// GraphURL is the URL of the site graph page, GraphDataURL the URL of the
+// graph data loaded by graph.js.
+const (
+	GraphURL     = "/graph"
+	GraphDataURL = "/graph.json"
+)
+
+type GraphPageData struct {
+	Name       template.HTML
+	Suffix     template.HTML
+	Explorer   template.HTML
+	Socials    template.HTML
+	LiveReload bool
+	Lang       string
+	T          UIStrings
+
+	TotalNotes int
+	TotalTags  int
+	TotalLinks int
+	ShowTags   bool
+	Graph      template.HTML
+}
+
+// BuildGraph returns the graph of the whole site: a node for every note, a
+// link for every note linking to another note and, with graph.tags, a node for
+// every tag of a language linked from the notes carrying it. Links to headings
+// count as links to the note, links to notes that do not exist are left out.
+// Notes in graph.exclude_folders or tagged with one of graph.exclude_tags are
+// left out too, and so are notes without links when graph.hide_orphans is set.
+func BuildGraph(cfg *config.Config, pages []types.MetaMarkdown) *types.GraphData {
+	nodes := make([]types.GraphNode, 0, len(pages))
 	links := make([]types.GraphLink, 0)
-	nodeSet := make(map[string]bool)
-
-	ensureNode := func(id, title string) {
-		if !nodeSet[id] {
-			nodes = append(nodes, types.GraphNode{
-				ID:    id,
-				Title: title,
-				URL:   id,
-			})
-			nodeSet[id] = true
+
+	included := make([]types.MetaMarkdown, 0, len(pages))
+	index := make(map[string]int, len(pages))
+	for _, page := range pages {
+		id := pageGraphID(page)
+		if _, ok := index[id]; ok || excludedFromGraph(cfg, page) {
+			continue
 		}
+		index[id] = len(nodes)
+		included = append(included, page)
+
+		nodes = append(nodes, types.GraphNode{
+			ID:        id,
+			Title:     page.Title,
+			URL:       id,
+			Type:      types.GraphNodeNote,
+			Tags:      page.Tags,
+			Folder:    pageFolder(page),
+			WordCount: page.WordCount,
+		})
 	}
 
-	currentPageURL := page.Link
-	if currentPageURL == "" {
-		currentPageURL = "/" + utils.PathToSlug(page.RelativePath)
+	seen := make(map[types.GraphLink]bool)
+	linked := make(map[string]bool)
+	for _, page := range included {
+		source := pageGraphID(page)
+		for _, out := range page.OutgoingLinks {
+			target, _, _ := strings.Cut(out.URL, "#")
+			if _, ok := index[target]; !ok || target == source {
+				continue
+			}
+
+			link := types.GraphLink{Source: source, Target: target}
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			links = append(links, link)
+			nodes[index[target]].Backlinks++
+			linked[source], linked[target] = true, true
+		}
 	}
 
-	ensureNode(currentPageURL, page.Title)
+	if cfg.Graph.HideOrphans {
+		kept := nodes[:0]
+		keptPages := included[:0]
+		for i, node := range nodes {
+			if linked[node.ID] {
+				kept = append(kept, node)
+				keptPages = append(keptPages, included[i])
+			}
+		}
+		nodes, included = kept, keptPages
 
-	for _, out := range page.OutgoingLinks {
-		ensureNode(out.URL, out.Title)
-		links = append(links, types.GraphLink{
-			Source: currentPageURL,
-			Target: out.URL,
-		})
+		index = make(map[string]int, len(nodes))
+		for i, node := range nodes {
+			index[node.ID] = i
+		}
 	}
 
-	for _, back := range page.Backlinks {
-		ensureNode(back.URL, back.Title)
-		links = append(links, types.GraphLink{
-			Source: back.URL,
-			Target: currentPageURL,
-		})
+	if cfg.Graph.Tags {
+		for _, page := range included {
+			source := pageGraphID(page)
+			for _, tag := range page.Tags {
+				// Every language has its own tag pages.
+				id := TagURL(cfg, page.Lang, tag)
+				if _, ok := index[id]; !ok {
+					index[id] = len(nodes)
+					nodes = append(nodes, types.GraphNode{
+						ID:    id,
+						Title: "#" + tag,
+						URL:   id,
+						Type:  types.GraphNodeTag,
+					})
+				}
+
+				link := types.GraphLink{Source: source, Target: id}
+				if seen[link] {
+					continue
+				}
+				seen[link] = true
+				links = append(links, link)
+				nodes[index[id]].Backlinks++
+			}
+		}
 	}
 
-	return &types.GraphData{
+	graph := &types.GraphData{
 		Nodes: nodes,
 		Links: links,
 	}
+	ComputeGraphMetrics(graph)
+	return graph
+}
+
+func excludedFromGraph(cfg *config.Config, page types.MetaMarkdown) bool {
+	folder := pageFolder(page)
+	for _, excluded := range cfg.Graph.ExcludeFolders {
+		excluded = strings.Trim(filepath.ToSlash(excluded), "/")
+		if excluded != "" && (folder == excluded || strings.HasPrefix(folder, excluded+"/")) {
+			return true
+		}
+	}
+
+	for _, tag := range page.Tags {
+		for _, excluded := range cfg.Graph.ExcludeTags {
+			excluded = strings.TrimPrefix(strings.TrimSpace(excluded), "#")
+			if tag == excluded || strings.HasPrefix(tag, excluded+"/") {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
-func RenderGraphView(graphData *types.GraphData, currentPageURL string) string {
-	if graphData == nil || len(graphData.Nodes) == 0 {
+func pageFolder(page types.MetaMarkdown) string {
+	folder := path.Dir(filepath.ToSlash(page.RelativePath))
+	if folder == "." {
 		return ""
 	}
+	return folder
+}
 
-	filteredNodes := make([]types.GraphNode, 0)
-	filteredLinks := make([]types.GraphLink, 0)
-	nodeSet := make(map[string]bool)
+func pageGraphID(page types.MetaMarkdown) string {
+	if page.Link != "" {
+		return page.Link
+	}
+	return "/" + utils.PageSlug(page.RelativePath)
+}
 
-	nodeSet[currentPageURL] = true
+// RenderGraphView renders the graph panel of a page. graph.js loads the site
+// graph and shows the notes up to graph.depth links away from currentPageURL,
+// following the links selected by graph.links. Notes with "graph: false" in
+// their frontmatter get no graph panel.
+func RenderGraphView(cfg *config.Config, page types.MetaMarkdown, currentPageURL string) string {
+	if v, ok := page.Frontmatter["graph"].(bool); ok && !v {
+		return ""
+	}
 
-	for _, link := range graphData.Links {
-		if link.Source == currentPageURL {
-			nodeSet[link.Target] = true
-		} else if link.Target == currentPageURL {
-			nodeSet[link.Source] = true
-		}
+	return fmt.Sprintf(`<div id="graph-container" data-graph-src=%q data-current-page=%q data-depth="%d" data-links=%q data-tags="%t"></div>`,
+		html.EscapeString(GraphDataURL),
+		html.EscapeString(currentPageURL),
+		cfg.Graph.Depth,
+		html.EscapeString(cfg.Graph.Links),
+		cfg.Graph.Tags)
+}
+
+// BuildGraphPage writes graph.json and, when the theme has a graph.html
+// template, the /graph page showing the whole site.
+func BuildGraphPage(cfg *config.Config, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+	graph := BuildGraph(cfg, pages)
+
+	// Node ids stay root-relative; only the URLs graph.js navigates to move
+	// under the base path.
+	nodes := make([]types.GraphNode, len(graph.Nodes))
+	copy(nodes, graph.Nodes)
+	for i := range nodes {
+		nodes[i].URL = pageURL(cfg, nodes[i].URL)
 	}
 
-	for _, node := range graphData.Nodes {
-		if nodeSet[node.ID] {
-			filteredNodes = append(filteredNodes, node)
-		}
+	data, err := json.Marshal(types.GraphData{Nodes: nodes, Links: graph.Links})
+	if err != nil {
+		return fmt.Errorf("encode graph: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.Build.Output, strings.TrimPrefix(GraphDataURL, "/")), data, 0o644); err != nil {
+		return err
 	}
 
-	for _, link := range graphData.Links {
-		if nodeSet[link.Source] && nodeSet[link.Target] {
-			filteredLinks = append(filteredLinks, link)
-		}
+	templatePath := filepath.Join("themes", cfg.Theme, "templates", "graph.html")
+	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
+		return nil
 	}
 
-	filteredGraphData := &types.GraphData{
-		Nodes: filteredNodes,
-		Links: filteredLinks,
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return fmt.Errorf("parse graph template: %w", err)
 	}
 
-	jsonData, err := json.Marshal(filteredGraphData)
+	notes, tags := 0, 0
+	for _, node := range graph.Nodes {
+		if node.Type == types.GraphNodeTag {
+			tags++
+		} else {
+			notes++
+		}
+	}
+
+	strs, err := LoadUIStrings(cfg, cfg.I18n.Default)
 	if err != nil {
-		return ""
+		return err
+	}
+
+	page := GraphPageData{
+		Name:       template.HTML(cfg.Site.Name),
+		Suffix:     template.HTML(cfg.Site.Suffix),
+		Explorer:   template.HTML(RenderExplorer(fileTree, cfg.I18n.Default)),
+		Socials:    template.HTML(RenderSocials(cfg.Socials)),
+		LiveReload: liveReload,
+		Lang:       cfg.I18n.Default,
+		T:          strs,
+		TotalNotes: notes,
+		TotalTags:  tags,
+		TotalLinks: len(graph.Links),
+		ShowTags:   cfg.Graph.Tags,
+		Graph: template.HTML(fmt.Sprintf(`<div id="graph-container" class="graph-full" data-graph-src=%q></div>`,
+			html.EscapeString(GraphDataURL))),
 	}
 
-	return fmt.Sprintf(`<div id="graph-container" data-graph=%q data-current-page=%q></div>`,
-		html.EscapeString(string(jsonData)),
-		html.EscapeString(currentPageURL))
+	return writeHTML(cfg, tmpl, pageFile(cfg, GraphURL), page)
 }
@@ -0,0 +1,235 @@
+package build
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"geode/internal/types"
+)
+
+// GraphExportFormats lists the formats understood by ExportGraph.
+var GraphExportFormats = []string{"graphml", "gexf", "dot", "json"}
+
+// ExportGraph writes graph in one of GraphExportFormats so that it can be
+// opened in tools such as Gephi or Graphviz. Every node keeps its title, URL,
+// type, tags, folder, word count and backlink count as attributes.
+func ExportGraph(w io.Writer, graph *types.GraphData, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(graph)
+	case "dot":
+		return exportDOT(w, graph)
+	case "graphml":
+		return exportXML(w, graphML(graph))
+	case "gexf":
+		return exportXML(w, gexf(graph))
+	default:
+		return fmt.Errorf("unknown graph format %q, expected one of %s", format, strings.Join(GraphExportFormats, ", "))
+	}
+}
+
+func exportXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// nodeAttributes are exported for every node, in this order.
+var nodeAttributes = []struct {
+	Name string
+	Type string
+}{
+	{"title", "string"},
+	{"url", "string"},
+	{"type", "string"},
+	{"tags", "string"},
+	{"folder", "string"},
+	{"wordCount", "int"},
+	{"backlinks", "int"},
+}
+
+func nodeAttributeValues(n types.GraphNode) []string {
+	return []string{
+		n.Title,
+		n.URL,
+		n.Type,
+		strings.Join(n.Tags, ";"),
+		n.Folder,
+		strconv.Itoa(n.WordCount),
+		strconv.Itoa(n.Backlinks),
+	}
+}
+
+func exportDOT(w io.Writer, graph *types.GraphData) error {
+	var b strings.Builder
+	b.WriteString("digraph geode {\n")
+	for _, n := range graph.Nodes {
+		values := nodeAttributeValues(n)
+		attrs := make([]string, 0, len(values)+1)
+		attrs = append(attrs, "label="+dotQuote(n.Title))
+		for i, attr := range nodeAttributes {
+			attrs = append(attrs, attr.Name+"="+dotQuote(values[i]))
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, l := range graph.Links {
+		fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(l.Source), dotQuote(l.Target))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+func graphML(graph *types.GraphData) graphMLDocument {
+	doc := graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphMLGraph{ID: "geode", EdgeDefault: "directed"},
+	}
+	for _, attr := range nodeAttributes {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: attr.Name, For: "node", Name: attr.Name, Type: attr.Type})
+	}
+
+	for _, n := range graph.Nodes {
+		node := graphMLNode{ID: n.ID}
+		for i, value := range nodeAttributeValues(n) {
+			node.Data = append(node.Data, graphMLData{Key: nodeAttributes[i].Name, Value: value})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, l := range graph.Links {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: l.Source, Target: l.Target})
+	}
+
+	return doc
+}
+
+type gexfDocument struct {
+	XMLName xml.Name  `xml:"gexf"`
+	XMLNS   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string         `xml:"defaultedgetype,attr"`
+	Attributes      gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode     `xml:"nodes>node"`
+	Edges           []gexfEdge     `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfEdge struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+func gexf(graph *types.GraphData) gexfDocument {
+	doc := gexfDocument{
+		XMLNS:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Graph: gexfGraph{
+			DefaultEdgeType: "directed",
+			Attributes:      gexfAttributes{Class: "node"},
+		},
+	}
+	for _, attr := range nodeAttributes {
+		typ := attr.Type
+		if typ == "int" {
+			typ = "integer"
+		}
+		doc.Graph.Attributes.Attributes = append(doc.Graph.Attributes.Attributes,
+			gexfAttribute{ID: attr.Name, Title: attr.Name, Type: typ})
+	}
+
+	for _, n := range graph.Nodes {
+		node := gexfNode{ID: n.ID, Label: n.Title}
+		for i, value := range nodeAttributeValues(n) {
+			node.AttValues = append(node.AttValues, gexfAttValue{For: nodeAttributes[i].Name, Value: value})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for i, l := range graph.Links {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{ID: strconv.Itoa(i), Source: l.Source, Target: l.Target})
+	}
+
+	return doc
+}
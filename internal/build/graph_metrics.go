@@ -0,0 +1,213 @@
+package build
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/types"
+)
+
+const (
+	pageRankDamping    = 0.85
+	pageRankIterations = 100
+	pageRankTolerance  = 1e-9
+)
+
+// GraphMetrics summarises the link structure between the notes of a graph.
+type GraphMetrics struct {
+	Notes      int               `json:"notes"`
+	Links      int               `json:"links"`
+	Components int               `json:"components"`
+	Orphans    []types.GraphNode `json:"orphans"`  // no links in or out
+	DeadEnds   []types.GraphNode `json:"deadEnds"` // linked to, but linking nowhere
+}
+
+// ComputeGraphMetrics sets the in and out degree, PageRank and connected
+// component of every note of graph and returns a summary. Tag nodes and
+// links to tags are ignored.
+func ComputeGraphMetrics(graph *types.GraphData) GraphMetrics {
+	index := make(map[string]int)
+	var notes []int
+	for i, node := range graph.Nodes {
+		if node.Type == types.GraphNodeTag {
+			continue
+		}
+		graph.Nodes[i].InDegree, graph.Nodes[i].OutDegree = 0, 0
+		index[node.ID] = len(notes)
+		notes = append(notes, i)
+	}
+
+	n := len(notes)
+	out := make([][]int, n)
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	links := 0
+	for _, link := range graph.Links {
+		s, ok := index[link.Source]
+		if !ok {
+			continue
+		}
+		t, ok := index[link.Target]
+		if !ok {
+			continue
+		}
+		links++
+		out[s] = append(out[s], t)
+		graph.Nodes[notes[s]].OutDegree++
+		graph.Nodes[notes[t]].InDegree++
+
+		if rs, rt := find(s), find(t); rs != rt {
+			parent[rt] = rs
+		}
+	}
+
+	components := make(map[int]int)
+	for i, node := range notes {
+		root := find(i)
+		if _, ok := components[root]; !ok {
+			components[root] = len(components) + 1
+		}
+		graph.Nodes[node].Component = components[root]
+	}
+
+	for i, rank := range pageRank(out) {
+		graph.Nodes[notes[i]].PageRank = rank
+	}
+
+	metrics := GraphMetrics{
+		Notes:      n,
+		Links:      links,
+		Components: len(components),
+		Orphans:    []types.GraphNode{},
+		DeadEnds:   []types.GraphNode{},
+	}
+	for _, i := range notes {
+		node := graph.Nodes[i]
+		switch {
+		case node.InDegree == 0 && node.OutDegree == 0:
+			metrics.Orphans = append(metrics.Orphans, node)
+		case node.OutDegree == 0:
+			metrics.DeadEnds = append(metrics.DeadEnds, node)
+		}
+	}
+
+	return metrics
+}
+
+// pageRank runs PageRank over the adjacency list out. The rank of notes
+// without outgoing links is spread over all notes.
+func pageRank(out [][]int) []float64 {
+	n := len(out)
+	if n == 0 {
+		return nil
+	}
+
+	rank := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+
+	next := make([]float64, n)
+	for iter := 0; iter < pageRankIterations; iter++ {
+		dangling := 0.0
+		for i, targets := range out {
+			if len(targets) == 0 {
+				dangling += rank[i]
+			}
+		}
+
+		base := (1-pageRankDamping)/float64(n) + pageRankDamping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for i, targets := range out {
+			share := pageRankDamping * rank[i] / float64(len(targets))
+			for _, t := range targets {
+				next[t] += share
+			}
+		}
+
+		delta := 0.0
+		for i := range rank {
+			delta += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if delta < pageRankTolerance {
+			break
+		}
+	}
+
+	return rank
+}
+
+// MostReferenced returns up to limit notes with the most notes linking to
+// them, ties broken by PageRank and then by title. Notes nobody links to are
+// left out.
+func MostReferenced(graph *types.GraphData, limit int) []types.GraphNode {
+	var nodes []types.GraphNode
+	for _, node := range graph.Nodes {
+		if node.Type != types.GraphNodeTag && node.InDegree > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].InDegree != nodes[j].InDegree {
+			return nodes[i].InDegree > nodes[j].InDegree
+		}
+		if nodes[i].PageRank != nodes[j].PageRank {
+			return nodes[i].PageRank > nodes[j].PageRank
+		}
+		return strings.ToLower(nodes[i].Title) < strings.ToLower(nodes[j].Title)
+	})
+
+	if limit > 0 && len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+	return nodes
+}
+
+// MostReferencedLinks returns links to the limit most referenced notes of
+// the site graph.
+func MostReferencedLinks(cfg *config.Config, pages []types.MetaMarkdown, limit int) []types.Link {
+	nodes := MostReferenced(BuildGraph(cfg, pages), limit)
+	links := make([]types.Link, len(nodes))
+	for i, node := range nodes {
+		links[i] = types.Link{Title: node.Title, URL: node.URL}
+	}
+	return links
+}
+
+// TopPageRank returns up to limit notes ordered by PageRank.
+func TopPageRank(graph *types.GraphData, limit int) []types.GraphNode {
+	var nodes []types.GraphNode
+	for _, node := range graph.Nodes {
+		if node.Type != types.GraphNodeTag {
+			nodes = append(nodes, node)
+		}
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].PageRank != nodes[j].PageRank {
+			return nodes[i].PageRank > nodes[j].PageRank
+		}
+		return strings.ToLower(nodes[i].Title) < strings.ToLower(nodes[j].Title)
+	})
+
+	if limit > 0 && len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+	return nodes
+}
@@ -0,0 +1,150 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/types"
+	"geode/internal/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UIStrings are the interface strings of a theme in one language, used in
+// templates as {{ .T.backlinks }}. Strings taking a number or a name are
+// format strings: {{ printf .T.reading_time .ReadingTime }}.
+type UIStrings map[string]string
+
+// LoadUIStrings reads themes/<theme>/i18n/<lang>.yaml. Missing strings fall
+// back to the default language of the site, then to English.
+func LoadUIStrings(cfg *config.Config, lang string) (UIStrings, error) {
+	strs := UIStrings{}
+	for _, code := range []string{config.DefaultLanguage, cfg.I18n.Default, lang} {
+		data, err := os.ReadFile(filepath.Join("themes", cfg.Theme, "i18n", code+".yaml"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &strs); err != nil {
+			return nil, fmt.Errorf("parse i18n/%s.yaml: %w", code, err)
+		}
+	}
+	return strs, nil
+}
+
+// Translation is one language version of a note, for hreflang links and the
+// language switcher.
+type Translation struct {
+	Lang    string
+	Name    string
+	URL     string
+	Current bool
+}
+
+// Translations groups the notes sharing a translation key, in the order of
+// i18n.languages. Keys with a single language are left out.
+func Translations(cfg *config.Config, pages []types.MetaMarkdown) map[string][]Translation {
+	if !cfg.Multilingual() {
+		return nil
+	}
+
+	byKey := make(map[string]map[string]types.MetaMarkdown)
+	for _, p := range pages {
+		if byKey[p.TranslationKey] == nil {
+			byKey[p.TranslationKey] = make(map[string]types.MetaMarkdown)
+		}
+		// The first note of a language wins, like pages sharing a permalink.
+		if _, ok := byKey[p.TranslationKey][p.Lang]; !ok {
+			byKey[p.TranslationKey][p.Lang] = p
+		}
+	}
+
+	out := make(map[string][]Translation)
+	for key, langs := range byKey {
+		if len(langs) < 2 {
+			continue
+		}
+		for _, lang := range cfg.I18n.Languages {
+			p, ok := langs[lang.Code]
+			if !ok {
+				continue
+			}
+			out[key] = append(out[key], Translation{Lang: lang.Code, Name: lang.Name, URL: notePageURL(p)})
+		}
+	}
+	return out
+}
+
+// pageTranslations returns the translations of page with its own language
+// marked as current.
+func pageTranslations(translations map[string][]Translation, page types.MetaMarkdown) []Translation {
+	all := translations[page.TranslationKey]
+	if len(all) == 0 {
+		return nil
+	}
+
+	out := make([]Translation, len(all))
+	for i, t := range all {
+		t.Current = t.Lang == page.Lang
+		out[i] = t
+	}
+	return out
+}
+
+// renderAlternateLinks renders the hreflang <link> elements of a note. The
+// default language version doubles as x-default.
+func renderAlternateLinks(cfg *config.Config, translations []Translation) template.HTML {
+	links := make([]string, 0, len(translations)+1)
+	for _, t := range translations {
+		links = append(links, fmt.Sprintf(`<link rel="alternate" hreflang="%s" href="%s" />`,
+			template.HTMLEscapeString(t.Lang),
+			template.HTMLEscapeString(canonicalURL(cfg, t.URL))))
+		if t.Lang == cfg.I18n.Default {
+			links = append(links, fmt.Sprintf(`<link rel="alternate" hreflang="x-default" href="%s" />`,
+				template.HTMLEscapeString(canonicalURL(cfg, t.URL))))
+		}
+	}
+	return template.HTML(strings.Join(links, "\n    "))
+}
+
+// LanguagePages returns the pages written in lang.
+func LanguagePages(pages []types.MetaMarkdown, lang string) []types.MetaMarkdown {
+	out := make([]types.MetaMarkdown, 0, len(pages))
+	for _, p := range pages {
+		if p.Lang == lang {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// folderLang returns the language of a folder: its language folder, if any,
+// else the default language.
+func folderLang(cfg *config.Config, folder string) string {
+	if cfg.Multilingual() {
+		top, _, _ := strings.Cut(folder, "/")
+		if _, ok := cfg.Language(top); ok {
+			return top
+		}
+	}
+	return cfg.I18n.Default
+}
+
+// TagURL returns the URL of the page of a tag in the listings of lang.
+func TagURL(cfg *config.Config, lang, tag string) string {
+	return cfg.LangPrefix(lang) + "/tags/" + escapeTagPath(tag)
+}
+
+func notePageURL(p types.MetaMarkdown) string {
+	if p.Link != "" {
+		return p.Link
+	}
+	return "/" + utils.PageSlug(p.RelativePath)
+}
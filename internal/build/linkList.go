@@ -30,6 +30,18 @@ func RenderLinkList(links []types.Link) string {
 		return sorted[i].Title < sorted[j].Title
 	})
 
+	return renderLinks(sorted)
+}
+
+// RenderRankedLinkList renders links in the given order.
+func RenderRankedLinkList(links []types.Link) string {
+	if len(links) == 0 {
+		return ""
+	}
+	return renderLinks(links)
+}
+
+func renderLinks(sorted []types.Link) string {
 	var b strings.Builder
 	b.WriteString(`<ul class="link-list">`)
 	for _, l := range sorted {
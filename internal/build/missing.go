@@ -0,0 +1,107 @@
+package build
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/render/wikilink"
+	"geode/internal/types"
+)
+
+type MissingTarget struct {
+	ID     string
+	Target string
+	Pages  []types.Link
+}
+
+type MissingData struct {
+	Name       template.HTML
+	Suffix     template.HTML
+	Explorer   template.HTML
+	Socials    template.HTML
+	LiveReload bool
+	Lang       string
+	T          UIStrings
+
+	TotalTargets int
+	Targets      []MissingTarget
+}
+
+// BuildMissingPage lists every wikilink target that does not exist together
+// with the notes linking to it. Themes without a missing.html template skip it.
+func BuildMissingPage(cfg *config.Config, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+	templatePath := filepath.Join("themes", cfg.Theme, "templates", "missing.html")
+	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
+		return nil
+	}
+
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return fmt.Errorf("parse missing template: %w", err)
+	}
+
+	// Targets that differ only in case, or that share their anchor
+	// otherwise, are listed once, under the spelling that sorts first.
+	byAnchor := make(map[string]*MissingTarget)
+	seen := make(map[string]bool)
+	for _, p := range pages {
+		for _, target := range p.MissingLinks {
+			id := wikilink.MissingAnchor(target)
+			item, ok := byAnchor[id]
+			if !ok {
+				item = &MissingTarget{ID: id, Target: target}
+				byAnchor[id] = item
+			} else if target < item.Target {
+				item.Target = target
+			}
+			if !seen[id+"\x00"+p.Link] {
+				seen[id+"\x00"+p.Link] = true
+				item.Pages = append(item.Pages, types.Link{Title: p.Title, URL: p.Link})
+			}
+		}
+	}
+
+	items := make([]MissingTarget, 0, len(byAnchor))
+	for _, item := range byAnchor {
+		refs := item.Pages
+		sort.SliceStable(refs, func(i, j int) bool {
+			a, b := strings.ToLower(refs[i].Title), strings.ToLower(refs[j].Title)
+			if a != b {
+				return a < b
+			}
+			return refs[i].URL < refs[j].URL
+		})
+		items = append(items, *item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := strings.ToLower(items[i].Target), strings.ToLower(items[j].Target)
+		if a != b {
+			return a < b
+		}
+		return items[i].Target < items[j].Target
+	})
+
+	strs, err := LoadUIStrings(cfg, cfg.I18n.Default)
+	if err != nil {
+		return err
+	}
+
+	data := MissingData{
+		Name:         template.HTML(cfg.Site.Name),
+		Suffix:       template.HTML(cfg.Site.Suffix),
+		Explorer:     template.HTML(RenderExplorer(fileTree, cfg.I18n.Default)),
+		Socials:      template.HTML(RenderSocials(cfg.Socials)),
+		LiveReload:   liveReload,
+		Lang:         cfg.I18n.Default,
+		T:            strs,
+		TotalTargets: len(items),
+		Targets:      items,
+	}
+
+	return writeHTML(cfg, tmpl, pageFile(cfg, wikilink.MissingPage), data)
+}
@@ -15,6 +15,8 @@ type NotFoundData struct {
 	Explorer   template.HTML
 	Socials    template.HTML
 	LiveReload bool
+	Lang       string
+	T          UIStrings
 	HasTwitter bool
 	HasMermaid bool
 }
@@ -30,21 +32,22 @@ func Build404(cfg *config.Config, liveReload bool, fileTree *types.FileTree) err
 		return fmt.Errorf("parse 404 template: %w", err)
 	}
 
+	strs, err := LoadUIStrings(cfg, cfg.I18n.Default)
+	if err != nil {
+		return err
+	}
+
 	data := NotFoundData{
 		Name:       template.HTML(cfg.Site.Name),
-		Explorer:   template.HTML(RenderExplorer(fileTree)),
+		Explorer:   template.HTML(RenderExplorer(fileTree, cfg.I18n.Default)),
 		Socials:    template.HTML(RenderSocials(cfg.Socials)),
 		LiveReload: liveReload,
+		Lang:       cfg.I18n.Default,
+		T:          strs,
 		HasTwitter: false,
 		HasMermaid: false,
 	}
 
-	outPath := filepath.Join("public", "404.html")
-	f, err := os.Create(outPath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	return tmpl.Execute(f, data)
+	outPath := filepath.Join(cfg.Build.Output, "404.html")
+	return writeHTML(cfg, tmpl, outPath, data)
 }
@@ -0,0 +1,81 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/ogimage"
+	"geode/internal/types"
+	"geode/internal/utils"
+)
+
+// socialCardSuffix replaces ".html" in the output file of a page to name its
+// card, so that cards never collide with images of the content.
+const socialCardSuffix = ".og.png"
+
+// canonicalURL returns the absolute URL of a page.
+func canonicalURL(cfg *config.Config, pageURL string) string {
+	url := strings.TrimSuffix(pageURL, "/index")
+	if url == "" {
+		url = "/"
+	}
+	return cfg.SiteURL() + trailingSlash(cfg, url)
+}
+
+// socialImage returns the absolute URL of the image shown when a page is
+// shared. The "image" frontmatter wins; otherwise the card of the page is
+// rendered next to outputPath when the theme defines one.
+func socialImage(cfg *config.Config, cards *ogimage.Renderer, strs UIStrings, page types.MetaMarkdown, outputPath string) (string, error) {
+	if image, ok := page.Frontmatter["image"].(string); ok && strings.TrimSpace(image) != "" {
+		return frontmatterImage(cfg, page, strings.TrimSpace(image)), nil
+	}
+
+	if cards == nil {
+		return "", nil
+	}
+
+	card := ogimage.Card{
+		SiteName: cfg.Site.Name,
+		Title:    page.Title,
+		Tags:     page.Tags,
+	}
+	if page.ReadingTime > 0 && strs["reading_time"] != "" {
+		card.ReadingTime = fmt.Sprintf(strs["reading_time"], page.ReadingTime)
+	}
+
+	var buf bytes.Buffer
+	err := cards.Render(&buf, card)
+	if err != nil {
+		return "", err
+	}
+
+	cardPath := strings.TrimSuffix(outputPath, ".html") + socialCardSuffix
+	if err := os.WriteFile(cardPath, buf.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(cfg.Build.Output, cardPath)
+	if err != nil {
+		return "", err
+	}
+	return cfg.SiteURL() + "/" + filepath.ToSlash(rel), nil
+}
+
+// frontmatterImage resolves the "image" frontmatter: absolute URLs are kept,
+// paths starting with "/" are from the site root and other paths are relative
+// to the note.
+func frontmatterImage(cfg *config.Config, page types.MetaMarkdown, image string) string {
+	if strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://") {
+		return image
+	}
+
+	if !strings.HasPrefix(image, "/") {
+		image = path.Join(path.Dir(filepath.ToSlash(page.RelativePath)), image)
+	}
+	return cfg.SiteURL() + "/" + utils.PathToSlug(strings.TrimPrefix(path.Clean("/"+image), "/"))
+}
@@ -0,0 +1,239 @@
+package build
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/types"
+	"geode/internal/utils"
+)
+
+type Redirect struct {
+	From   string
+	To     string
+	Source string // note that declared the redirect
+}
+
+// reservedURLs are generated by Geode itself and cannot be redirected.
+// langReservedURLs are generated for every language, under its prefix.
+var (
+	reservedURLs     = []string{"/404", GraphURL, TasksURL, TasksDataURL}
+	langReservedURLs = []string{"/tags", "/_missing", "/" + rssFile, "/" + atomFile, "/" + jsonFile}
+)
+
+var redirectTemplate = template.Must(template.New("redirect").Parse(`<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8" />
+    <title>Redirecting to {{ .To }}</title>
+    <link rel="canonical" href="{{ .Canonical }}" />
+    <meta name="robots" content="noindex" />
+    <meta http-equiv="refresh" content="0; url={{ .To }}" />
+  </head>
+  <body>
+    <p>This page has moved to <a href="{{ .To }}">{{ .To }}</a>.</p>
+  </body>
+</html>
+`))
+
+// CollectRedirects returns a redirect for every alias and redirect_from entry
+// of the pages. Aliases live next to the note they belong to, redirect_from
+// entries are paths from the site root. A redirect that would shadow a real
+// page, or two redirects with the same source and different targets, are
+// reported as errors, as are pages sharing a URL with another page.
+func CollectRedirects(cfg *config.Config, pages []types.MetaMarkdown) ([]Redirect, error) {
+	if collisions := URLCollisions(cfg, pages); len(collisions) > 0 {
+		c := collisions[0]
+		return nil, fmt.Errorf("page %s: %s", c.Page.RelativePath, c)
+	}
+
+	pageURLs := make(map[string]string, len(pages))
+	for _, p := range pages {
+		if p.Link != "" {
+			pageURLs[p.Link] = p.RelativePath
+		}
+	}
+
+	bySource := make(map[string]Redirect)
+	for _, p := range pages {
+		if p.Link == "" {
+			continue
+		}
+
+		dir := path.Dir(filepath.ToSlash(p.RelativePath))
+		var froms []string
+		for _, alias := range parseAliases(p.Frontmatter) {
+			froms = append(froms, path.Join(dir, alias))
+		}
+		froms = append(froms, parseRedirectFrom(p.Frontmatter)...)
+
+		for _, raw := range froms {
+			from := redirectURL(raw)
+			if from == "" || from == p.Link {
+				continue
+			}
+
+			if owner, ok := pageURLs[from]; ok {
+				return nil, fmt.Errorf("redirect %s declared in %s collides with page %s", from, p.RelativePath, owner)
+			}
+			if isReservedURL(cfg, from) {
+				return nil, fmt.Errorf("redirect %s declared in %s collides with a page generated by Geode", from, p.RelativePath)
+			}
+
+			if prev, ok := bySource[from]; ok {
+				if prev.To != p.Link {
+					return nil, fmt.Errorf("redirect %s is declared by both %s and %s", from, prev.Source, p.RelativePath)
+				}
+				continue
+			}
+
+			bySource[from] = Redirect{From: from, To: p.Link, Source: p.RelativePath}
+		}
+	}
+
+	redirects := make([]Redirect, 0, len(bySource))
+	for _, r := range bySource {
+		redirects = append(redirects, r)
+	}
+	sort.Slice(redirects, func(i, j int) bool {
+		return redirects[i].From < redirects[j].From
+	})
+
+	return redirects, nil
+}
+
+// BuildRedirects writes a static redirect page for every redirect plus a
+// _redirects file understood by Netlify and Cloudflare Pages.
+func BuildRedirects(cfg *config.Config, pages []types.MetaMarkdown) error {
+	redirects, err := CollectRedirects(cfg, pages)
+	if err != nil {
+		return err
+	}
+
+	var lines strings.Builder
+	for _, r := range redirects {
+		outPath := pageFile(cfg, r.From)
+		if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+			return err
+		}
+
+		f, err := os.Create(outPath)
+		if err != nil {
+			return err
+		}
+		err = redirectTemplate.Execute(f, map[string]string{
+			"To":        pageURL(cfg, r.To),
+			"Canonical": cfg.SiteURL() + trailingSlash(cfg, r.To),
+		})
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("write redirect %s: %w", r.From, err)
+		}
+
+		fmt.Fprintf(&lines, "%s %s 301\n", cfg.Site.BasePath+r.From, pageURL(cfg, r.To))
+	}
+
+	return os.WriteFile(filepath.Join(cfg.Build.Output, "_redirects"), []byte(lines.String()), 0o644)
+}
+
+// URLCollision is a page whose URL is taken by another note, Other, or by a
+// page generated by Geode when Other is empty.
+type URLCollision struct {
+	Page  types.MetaMarkdown
+	Other string
+}
+
+func (c URLCollision) String() string {
+	if c.Other == "" {
+		return fmt.Sprintf("%s collides with a page generated by Geode", c.Page.Link)
+	}
+	return fmt.Sprintf("%s collides with %s", c.Page.Link, c.Other)
+}
+
+// URLCollisions returns the pages whose URL is taken by a page generated by
+// Geode, like the tag index or the tasks page, or by another note, such as
+// Arch.canvas next to Arch.md. Of notes sharing a URL, the first by path
+// keeps it.
+func URLCollisions(cfg *config.Config, pages []types.MetaMarkdown) []URLCollision {
+	sorted := make([]types.MetaMarkdown, 0, len(pages))
+	for _, p := range pages {
+		if p.Link != "" {
+			sorted = append(sorted, p)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].RelativePath < sorted[j].RelativePath
+	})
+
+	var out []URLCollision
+	owners := make(map[string]string, len(sorted))
+	for _, p := range sorted {
+		if isReservedURL(cfg, p.Link) {
+			out = append(out, URLCollision{Page: p})
+			continue
+		}
+		if owner, ok := owners[p.Link]; ok {
+			out = append(out, URLCollision{Page: p, Other: owner})
+			continue
+		}
+		owners[p.Link] = p.RelativePath
+	}
+	return out
+}
+
+func isReservedURL(cfg *config.Config, url string) bool {
+	if slices.Contains(reservedURLs, url) {
+		return true
+	}
+	for _, lang := range cfg.LanguageCodes() {
+		prefix := cfg.LangPrefix(lang)
+		if strings.HasPrefix(url, prefix+"/tags/") {
+			return true
+		}
+		for _, reserved := range langReservedURLs {
+			if url == prefix+reserved {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func redirectURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimSuffix(raw, ".md")
+	raw = strings.Trim(path.Clean("/"+raw), "/")
+	if raw == "" || raw == "." {
+		return ""
+	}
+	return "/" + utils.PathToSlug(raw)
+}
+
+func parseRedirectFrom(front map[string]any) []string {
+	v, ok := front["redirect_from"]
+	if !ok || v == nil {
+		return nil
+	}
+
+	var out []string
+	switch vv := v.(type) {
+	case []any:
+		for _, it := range vv {
+			if s, ok := it.(string); ok {
+				out = append(out, s)
+			}
+		}
+	case string:
+		out = append(out, vv)
+	}
+	return out
+}
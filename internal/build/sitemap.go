@@ -12,23 +12,61 @@ import (
 )
 
 type SitemapURL struct {
-	Loc     string `xml:"loc"`
-	LastMod string `xml:"lastmod"`
+	Loc        string             `xml:"loc"`
+	LastMod    string             `xml:"lastmod"`
+	Alternates []SitemapAlternate `xml:"xhtml:link"`
+}
+
+// SitemapAlternate is an hreflang link to a translation of a page.
+type SitemapAlternate struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
 }
 
 type UrlSet struct {
 	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	XHTML   string       `xml:"xmlns:xhtml,attr,omitempty"`
 	URLs    []SitemapURL `xml:"url"`
 }
 
+type SitemapIndex struct {
+	XMLName  xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []SitemapPointer `xml:"sitemap"`
+}
+
+type SitemapPointer struct {
+	Loc string `xml:"loc"`
+}
+
+// BuildSitemap writes sitemap.xml. Multilingual sites get a sitemap per
+// language, sitemap-<lang>.xml, listed by a sitemap index in sitemap.xml, and
+// every translated page links its other versions.
 func BuildSitemap(cfg *config.Config, pages []types.MetaMarkdown) error {
-	outputDir := cfg.Build.Output
-	if outputDir == "" {
-		outputDir = "public"
+	if !cfg.Multilingual() {
+		return writeSitemap(cfg, "sitemap.xml", UrlSet{URLs: sitemapURLs(cfg, pages, nil)})
 	}
 
+	translations := Translations(cfg, pages)
+	var index SitemapIndex
+	for _, lang := range cfg.LanguageCodes() {
+		name := "sitemap-" + lang + ".xml"
+		urlSet := UrlSet{
+			XHTML: "http://www.w3.org/1999/xhtml",
+			URLs:  sitemapURLs(cfg, LanguagePages(pages, lang), translations),
+		}
+		if err := writeSitemap(cfg, name, urlSet); err != nil {
+			return err
+		}
+		index.Sitemaps = append(index.Sitemaps, SitemapPointer{Loc: cfg.SiteURL() + "/" + name})
+	}
+
+	return writeSitemap(cfg, "sitemap.xml", index)
+}
+
+func sitemapURLs(cfg *config.Config, pages []types.MetaMarkdown, translations map[string][]Translation) []SitemapURL {
 	var urls []SitemapURL
-	baseURL := cfg.Site.BaseURL
+	baseURL := cfg.SiteURL()
 
 	for _, page := range pages {
 		if page.Link == "" {
@@ -62,17 +100,29 @@ func BuildSitemap(cfg *config.Config, pages []types.MetaMarkdown) error {
 
 		url = strings.TrimSuffix(url, "/index")
 
+		var alternates []SitemapAlternate
+		for _, t := range translations[page.TranslationKey] {
+			alternates = append(alternates, SitemapAlternate{
+				Rel:      "alternate",
+				Hreflang: t.Lang,
+				Href:     canonicalURL(cfg, t.URL),
+			})
+		}
+
 		urls = append(urls, SitemapURL{
-			Loc:     baseURL + url,
-			LastMod: lastMod,
+			Loc:        baseURL + trailingSlash(cfg, url),
+			LastMod:    lastMod,
+			Alternates: alternates,
 		})
 	}
 
-	urlSet := UrlSet{URLs: urls}
+	return urls
+}
 
-	f, err := os.Create(filepath.Join(outputDir, "sitemap.xml"))
+func writeSitemap(cfg *config.Config, name string, v any) error {
+	f, err := os.Create(filepath.Join(cfg.Build.Output, name))
 	if err != nil {
-		return fmt.Errorf("create sitemap.xml: %w", err)
+		return fmt.Errorf("create %s: %w", name, err)
 	}
 	defer f.Close()
 
@@ -82,7 +132,7 @@ func BuildSitemap(cfg *config.Config, pages []types.MetaMarkdown) error {
 
 	encoder := xml.NewEncoder(f)
 	encoder.Indent("", "  ")
-	if err := encoder.Encode(urlSet); err != nil {
+	if err := encoder.Encode(v); err != nil {
 		return fmt.Errorf("encode sitemap: %w", err)
 	}
 
@@ -19,20 +19,37 @@ type TagDetailData struct {
 	Explorer   template.HTML
 	Socials    template.HTML
 	LiveReload bool
+	Lang       string
+	T          UIStrings
 
-	Tag        string
-	TagID      string
-	TotalItems int
-	Pages      []TagIndexPage
+	Tag         string
+	TagID       string
+	TagIndexURL string
+	Feeds       template.HTML
+	TotalItems  int
+	Pages       []TagIndexPage
 }
 
-func BuildTagPages(cfg *config.Config, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+// BuildTagPages writes the tag pages of the pages written in lang.
+func BuildTagPages(cfg *config.Config, lang string, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+	return BuildTagPagesFor(cfg, lang, pages, nil, liveReload, fileTree)
+}
+
+// BuildTagPagesFor only writes the pages of the given tags. A nil list builds
+// every tag; tags that no longer have any page get their output removed.
+func BuildTagPagesFor(cfg *config.Config, lang string, pages []types.MetaMarkdown, only []string, liveReload bool, fileTree *types.FileTree) error {
 	templatePath := filepath.Join("themes", cfg.Theme, "templates", "tag.html")
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
 		return fmt.Errorf("parse tag template: %w", err)
 	}
 
+	strs, err := LoadUIStrings(cfg, lang)
+	if err != nil {
+		return err
+	}
+	explorer := template.HTML(RenderExplorer(fileTree, lang))
+
 	byTag := make(map[string][]types.MetaMarkdown)
 	for _, p := range pages {
 		for _, raw := range p.Tags {
@@ -46,8 +63,27 @@ func BuildTagPages(cfg *config.Config, pages []types.MetaMarkdown, liveReload bo
 	}
 
 	tags := make([]string, 0, len(byTag))
-	for t := range byTag {
-		tags = append(tags, t)
+	if only == nil {
+		for t := range byTag {
+			tags = append(tags, t)
+		}
+	} else {
+		for _, raw := range only {
+			t := strings.TrimPrefix(strings.TrimSpace(raw), "#")
+			if t == "" {
+				continue
+			}
+			if _, ok := byTag[t]; !ok {
+				stale := append(tagFeedPaths(cfg, lang, t), pageFile(cfg, TagURL(cfg, lang, t)))
+				for _, path := range stale {
+					if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+						return err
+					}
+				}
+				continue
+			}
+			tags = append(tags, t)
+		}
 	}
 	sort.Strings(tags)
 
@@ -61,7 +97,7 @@ func BuildTagPages(cfg *config.Config, pages []types.MetaMarkdown, liveReload bo
 		for _, p := range ps {
 			pageURL := p.Link
 			if pageURL == "" {
-				pageURL = "/" + strings.TrimSuffix(utils.PathToSlug(p.RelativePath), ".md")
+				pageURL = "/" + utils.PageSlug(p.RelativePath)
 			}
 
 			pageTags := make([]string, 0, len(p.Tags))
@@ -77,37 +113,29 @@ func BuildTagPages(cfg *config.Config, pages []types.MetaMarkdown, liveReload bo
 
 			tagLinks := make([]TagLink, 0, len(pageTags))
 			for _, tt := range pageTags {
-				tagLinks = append(tagLinks, TagLink{Name: tt, URL: "/tags/" + escapeTagPath(tt)})
+				tagLinks = append(tagLinks, TagLink{Name: tt, URL: TagURL(cfg, lang, tt)})
 			}
 
-			items = append(items, TagIndexPage{Title: p.Title, URL: pageURL, Tags: tagLinks})
+			items = append(items, TagIndexPage{Title: p.Title, URL: pageURL, Tags: tagLinks, Fields: p.Fields})
 		}
 
 		data := TagDetailData{
-			Name:       template.HTML(cfg.Site.Name),
-			Suffix:     template.HTML(cfg.Site.Suffix),
-			Explorer:   template.HTML(RenderExplorer(fileTree)),
-			Socials:    template.HTML(""),
-			LiveReload: liveReload,
-			Tag:        tag,
-			TagID:      utils.PathToSlug(tag),
-			TotalItems: len(items),
-			Pages:      items,
+			Name:        template.HTML(cfg.Site.Name),
+			Suffix:      template.HTML(cfg.Site.Suffix),
+			Explorer:    explorer,
+			Socials:     template.HTML(""),
+			LiveReload:  liveReload,
+			Lang:        lang,
+			T:           strs,
+			Tag:         tag,
+			TagID:       utils.PathToSlug(tag),
+			TagIndexURL: cfg.LangPrefix(lang) + "/tags",
+			Feeds:       renderFeedLinks(TagFeeds(cfg, lang, tag)),
+			TotalItems:  len(items),
+			Pages:       items,
 		}
 
-		outPath := filepath.Join("public", "tags", escapeTagPath(tag)+".html")
-		if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
-			return err
-		}
-		f, err := os.Create(outPath)
-		if err != nil {
-			return err
-		}
-		if err := tmpl.Execute(f, data); err != nil {
-			_ = f.Close()
-			return err
-		}
-		if err := f.Close(); err != nil {
+		if err := writeHTML(cfg, tmpl, pageFile(cfg, TagURL(cfg, lang, tag)), data); err != nil {
 			return err
 		}
 	}
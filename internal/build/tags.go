@@ -4,9 +4,11 @@ import (
 	"html/template"
 	"net/url"
 	"strings"
+
+	"geode/internal/config"
 )
 
-func RenderTags(tags []string) string {
+func RenderTags(cfg *config.Config, lang string, tags []string) string {
 	if len(tags) == 0 {
 		return ""
 	}
@@ -23,7 +25,7 @@ func RenderTags(tags []string) string {
 			b.WriteString("\n")
 		}
 		first = false
-		href := "/tags/" + escapeTagPath(t)
+		href := TagURL(cfg, lang, t)
 		b.WriteString(`<li class="tag-item"><a class="tag" href="`)
 		b.WriteString(template.HTMLEscapeString(href))
 		b.WriteString(`">#`)
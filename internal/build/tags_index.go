@@ -3,7 +3,6 @@ package build
 import (
 	"fmt"
 	"html/template"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -14,9 +13,10 @@ import (
 )
 
 type TagIndexPage struct {
-	Title string
-	URL   string
-	Tags  []TagLink
+	Title  string
+	URL    string
+	Tags   []TagLink
+	Fields map[string]any
 }
 
 type TagLink struct {
@@ -36,18 +36,26 @@ type TagIndexData struct {
 	Explorer   template.HTML
 	Socials    template.HTML
 	LiveReload bool
+	Lang       string
+	T          UIStrings
 
 	TotalTags int
 	TagGroups []TagIndexGroup
 }
 
-func BuildTagsIndex(cfg *config.Config, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+// BuildTagsIndex writes the tag index of the pages written in lang.
+func BuildTagsIndex(cfg *config.Config, lang string, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
 	templatePath := filepath.Join("themes", cfg.Theme, "templates", "tags.html")
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
 		return fmt.Errorf("parse tags template: %w", err)
 	}
 
+	strs, err := LoadUIStrings(cfg, lang)
+	if err != nil {
+		return err
+	}
+
 	byTag := make(map[string][]types.MetaMarkdown)
 	for _, p := range pages {
 		for _, raw := range p.Tags {
@@ -77,7 +85,7 @@ func BuildTagsIndex(cfg *config.Config, pages []types.MetaMarkdown, liveReload b
 		for _, p := range ps {
 			url := p.Link
 			if url == "" {
-				url = "/" + strings.TrimSuffix(utils.PathToSlug(p.RelativePath), ".md")
+				url = "/" + utils.PageSlug(p.RelativePath)
 			}
 
 			pageTags := make([]string, 0, len(p.Tags))
@@ -95,14 +103,15 @@ func BuildTagsIndex(cfg *config.Config, pages []types.MetaMarkdown, liveReload b
 			for _, tt := range pageTags {
 				tagLinks = append(tagLinks, TagLink{
 					Name: tt,
-					URL:  "/tags/" + escapeTagPath(tt),
+					URL:  TagURL(cfg, lang, tt),
 				})
 			}
 
 			items = append(items, TagIndexPage{
-				Title: p.Title,
-				URL:   url,
-				Tags:  tagLinks,
+				Title:  p.Title,
+				URL:    url,
+				Tags:   tagLinks,
+				Fields: p.Fields,
 			})
 		}
 
@@ -112,23 +121,14 @@ func BuildTagsIndex(cfg *config.Config, pages []types.MetaMarkdown, liveReload b
 	data := TagIndexData{
 		Name:       template.HTML(cfg.Site.Name),
 		Suffix:     template.HTML(cfg.Site.Suffix),
-		Explorer:   template.HTML(RenderExplorer(fileTree)),
+		Explorer:   template.HTML(RenderExplorer(fileTree, lang)),
 		Socials:    template.HTML(""),
 		LiveReload: liveReload,
+		Lang:       lang,
+		T:          strs,
 		TotalTags:  len(tags),
 		TagGroups:  groups,
 	}
 
-	outPath := filepath.Join("public", "tags.html")
-	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
-		return err
-	}
-
-	f, err := os.Create(outPath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	return tmpl.Execute(f, data)
+	return writeHTML(cfg, tmpl, pageFile(cfg, cfg.LangPrefix(lang)+"/tags"), data)
 }
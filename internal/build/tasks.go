@@ -0,0 +1,203 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/types"
+	"geode/internal/utils"
+)
+
+// TasksURL is the URL of the page listing the tasks of every note,
+// TasksDataURL the URL of the same tasks as JSON.
+const (
+	TasksURL     = "/tasks"
+	TasksDataURL = "/tasks.json"
+)
+
+// TaskItem is a task together with the note it is written in. URL points
+// to the heading the task is under, or to the note.
+type TaskItem struct {
+	types.Task
+	Page    string
+	PageURL string
+	URL     string
+}
+
+type TaskGroup struct {
+	ID    string
+	Name  string
+	URL   string
+	Tasks []TaskItem
+}
+
+type TasksData struct {
+	Name       template.HTML
+	Suffix     template.HTML
+	Explorer   template.HTML
+	Socials    template.HTML
+	LiveReload bool
+	Lang       string
+	T          UIStrings
+
+	TotalOpen int
+	TotalDone int
+	ByNote    []TaskGroup
+	ByTag     []TaskGroup
+	ByDue     []TaskGroup
+}
+
+type taskExport struct {
+	types.Task
+	Page string `json:"page"`
+	URL  string `json:"url"`
+}
+
+// BuildTasksPage writes tasks.json and, when the theme has a tasks.html
+// template, the /tasks page grouping the tasks by note, by tag and by due
+// date. A task counts for its own tags and the tags of its note, and only
+// tasks with a due date are grouped by date.
+func BuildTasksPage(cfg *config.Config, pages []types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
+	var items []TaskItem
+	byNote := make(map[string][]TaskItem)
+	byTag := make(map[string][]TaskItem)
+	byDue := make(map[string][]TaskItem)
+	var notes []types.MetaMarkdown
+
+	for _, p := range pages {
+		if len(p.Tasks) == 0 {
+			continue
+		}
+		notes = append(notes, p)
+
+		for _, task := range p.Tasks {
+			item := TaskItem{Task: task, Page: p.Title, PageURL: p.Link, URL: p.Link}
+			if task.HeadingID != "" {
+				item.URL = p.Link + "#" + task.HeadingID
+			}
+			items = append(items, item)
+			byNote[p.Path] = append(byNote[p.Path], item)
+
+			seen := make(map[string]bool)
+			for _, raw := range append(append([]string(nil), task.Tags...), p.Tags...) {
+				tag := strings.TrimPrefix(strings.TrimSpace(raw), "#")
+				if tag == "" || seen[tag] {
+					continue
+				}
+				seen[tag] = true
+				byTag[tag] = append(byTag[tag], item)
+			}
+
+			if task.Due != "" {
+				byDue[task.Due] = append(byDue[task.Due], item)
+			}
+		}
+	}
+
+	export := make([]taskExport, len(items))
+	for i, item := range items {
+		export[i] = taskExport{Task: item.Task, Page: item.Page, URL: pageURL(cfg, item.URL)}
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		return fmt.Errorf("encode tasks: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.Build.Output, strings.TrimPrefix(TasksDataURL, "/")), data, 0o644); err != nil {
+		return err
+	}
+
+	templatePath := filepath.Join("themes", cfg.Theme, "templates", "tasks.html")
+	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
+		return nil
+	}
+
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return fmt.Errorf("parse tasks template: %w", err)
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		return strings.ToLower(notes[i].Title) < strings.ToLower(notes[j].Title)
+	})
+	noteGroups := make([]TaskGroup, 0, len(notes))
+	for _, p := range notes {
+		noteGroups = append(noteGroups, TaskGroup{
+			ID:    "note-" + utils.PageSlug(filepath.ToSlash(p.RelativePath)),
+			Name:  p.Title,
+			URL:   p.Link,
+			Tasks: openFirst(byNote[p.Path]),
+		})
+	}
+
+	tags := make([]string, 0, len(byTag))
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	tagGroups := make([]TaskGroup, 0, len(tags))
+	for _, tag := range tags {
+		tagGroups = append(tagGroups, TaskGroup{
+			ID:    "tag-" + utils.PathToSlug(tag),
+			Name:  "#" + tag,
+			Tasks: openFirst(byTag[tag]),
+		})
+	}
+
+	dates := make([]string, 0, len(byDue))
+	for date := range byDue {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	dueGroups := make([]TaskGroup, 0, len(dates))
+	for _, date := range dates {
+		dueGroups = append(dueGroups, TaskGroup{
+			ID:    "due-" + utils.PathToSlug(date),
+			Name:  date,
+			Tasks: openFirst(byDue[date]),
+		})
+	}
+
+	done := 0
+	for _, item := range items {
+		if item.Checked {
+			done++
+		}
+	}
+
+	strs, err := LoadUIStrings(cfg, cfg.I18n.Default)
+	if err != nil {
+		return err
+	}
+
+	page := TasksData{
+		Name:       template.HTML(cfg.Site.Name),
+		Suffix:     template.HTML(cfg.Site.Suffix),
+		Explorer:   template.HTML(RenderExplorer(fileTree, cfg.I18n.Default)),
+		Socials:    template.HTML(RenderSocials(cfg.Socials)),
+		LiveReload: liveReload,
+		Lang:       cfg.I18n.Default,
+		T:          strs,
+		TotalOpen:  len(items) - done,
+		TotalDone:  done,
+		ByNote:     noteGroups,
+		ByTag:      tagGroups,
+		ByDue:      dueGroups,
+	}
+
+	return writeHTML(cfg, tmpl, pageFile(cfg, TasksURL), page)
+}
+
+// openFirst puts the open tasks before the completed ones, keeping their
+// order otherwise.
+func openFirst(items []TaskItem) []TaskItem {
+	sort.SliceStable(items, func(i, j int) bool {
+		return !items[i].Checked && items[j].Checked
+	})
+	return items
+}
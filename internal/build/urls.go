@@ -0,0 +1,104 @@
+package build
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/content"
+)
+
+// rootURLAttrReg matches attributes holding a root-relative URL, but not
+// protocol-relative ones such as "//example.com".
+var rootURLAttrReg = regexp.MustCompile(`\b(href|src|action|data-graph-src)="(/[^/"][^"]*|/)"`)
+
+// siteFileExt are the extensions of the files Geode and themes write next to
+// the pages; links to them never get a trailing slash.
+var siteFileExt = map[string]bool{
+	".css": true, ".js": true, ".json": true, ".xml": true, ".ico": true,
+	".html": true, ".txt": true, ".webmanifest": true,
+}
+
+// rewriteURLs applies build.url_style to the links of a generated page and
+// puts prefix, the base path or the absolute site URL, in front of every
+// root-relative URL.
+func rewriteURLs(cfg *config.Config, html []byte, prefix string) []byte {
+	if prefix == "" && cfg.Build.URLStyle != config.URLStyleDirectory {
+		return html
+	}
+
+	return rootURLAttrReg.ReplaceAllFunc(html, func(m []byte) []byte {
+		sub := rootURLAttrReg.FindSubmatch(m)
+		attr, url := string(sub[1]), string(sub[2])
+		if attr == "href" {
+			url = trailingSlash(cfg, url)
+		}
+		return []byte(attr + `="` + prefix + url + `"`)
+	})
+}
+
+// trailingSlash applies build.url_style to a root-relative URL: pages end
+// with a slash in the directory style, files keep their name.
+func trailingSlash(cfg *config.Config, url string) string {
+	if cfg.Build.URLStyle != config.URLStyleDirectory {
+		return url
+	}
+
+	p, rest := url, ""
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		p, rest = url[:i], url[i:]
+	}
+	if p == "" || strings.HasSuffix(p, "/") {
+		return url
+	}
+
+	ext := strings.ToLower(path.Ext(p))
+	if ext != "" && (siteFileExt[ext] || content.IsAssetFile(ext)) {
+		return url
+	}
+
+	if p == "/index" {
+		return "/" + rest
+	}
+	return p + "/" + rest
+}
+
+// pageURL returns the URL a page is linked with: under site.base_path and
+// following build.url_style.
+func pageURL(cfg *config.Config, url string) string {
+	return cfg.Site.BasePath + trailingSlash(cfg, url)
+}
+
+// pageFile returns the output file of the page at url, either url.html or
+// url/index.html following build.url_style. The site root is index.html in
+// both styles.
+func pageFile(cfg *config.Config, url string) string {
+	p := strings.Trim(url, "/")
+	if p == "" || p == "index" {
+		return filepath.Join(cfg.Build.Output, "index.html")
+	}
+
+	if cfg.Build.URLStyle == config.URLStyleDirectory {
+		return filepath.Join(cfg.Build.Output, filepath.FromSlash(p), "index.html")
+	}
+	return filepath.Join(cfg.Build.Output, filepath.FromSlash(p)+".html")
+}
+
+// writeHTML executes tmpl and writes the page to outPath, with its links
+// under the base path and following build.url_style.
+func writeHTML(cfg *config.Config, tmpl *template.Template, outPath string, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, rewriteURLs(cfg, buf.Bytes(), cfg.Site.BasePath), 0o644)
+}
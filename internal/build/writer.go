@@ -7,9 +7,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"geode/internal/config"
+	"geode/internal/ogimage"
 	"geode/internal/types"
 	"geode/internal/utils"
 )
@@ -27,22 +29,48 @@ type PageData struct {
 	Toc           template.HTML
 	OutgoingLinks template.HTML
 	Backlinks     template.HTML
-	Socials       template.HTML
-	HasKatex      bool
-	HasMermaid    bool
-	HasTwitter    bool
-	LiveReload    bool
-	CSSClasses    []string
-	Description   string
-	Keywords      []string
-	Date          template.HTML
-	Pagefind      bool
-	Aliases       template.HTML
+	// MostReferenced is only set on the index page.
+	MostReferenced template.HTML
+	Socials        template.HTML
+	HasKatex       bool
+	HasMermaid     bool
+	HasTwitter     bool
+	LiveReload     bool
+	CSSClasses     []string
+	Description    string
+	Keywords       []string
+	Date           template.HTML
+	Pagefind       bool
+	Aliases        template.HTML
+	Feeds          template.HTML
+	Canonical      string
+	SocialImage    string
+	Lang           string
+	T              UIStrings
+	Translations   []Translation
+	Alternates     template.HTML
+	// Fields holds the frontmatter and the inline fields of the note.
+	Fields map[string]any
 }
 
 type HTMLWriter struct {
-	tmpl *template.Template
-	cfg  *config.Config
+	tmpl    *template.Template
+	cfg     *config.Config
+	cards   *ogimage.Renderer
+	strings map[string]UIStrings
+
+	// MostReferenced is listed on the index page.
+	MostReferenced []types.Link
+
+	// Translations are the language versions of the notes, by translation
+	// key.
+	Translations map[string][]Translation
+
+	// Write is called from several goroutines; the explorer is rendered once
+	// per file tree and language because rendering it sorts the tree in place.
+	mu           sync.Mutex
+	explorerTree *types.FileTree
+	explorerHTML map[string]string
 }
 
 func NewHTMLWriter(cfg *config.Config) (*HTMLWriter, error) {
@@ -52,19 +80,31 @@ func NewHTMLWriter(cfg *config.Config) (*HTMLWriter, error) {
 		return nil, fmt.Errorf("parse template: %w", err)
 	}
 
+	cards, err := ogimage.Load(filepath.Join("themes", cfg.Theme))
+	if err != nil {
+		return nil, fmt.Errorf("load social card template: %w", err)
+	}
+
+	uiStrings := make(map[string]UIStrings)
+	for _, lang := range cfg.LanguageCodes() {
+		if uiStrings[lang], err = LoadUIStrings(cfg, lang); err != nil {
+			return nil, fmt.Errorf("load ui strings: %w", err)
+		}
+	}
+
 	return &HTMLWriter{
-		tmpl: tmpl,
-		cfg:  cfg,
+		tmpl:    tmpl,
+		cfg:     cfg,
+		cards:   cards,
+		strings: uiStrings,
 	}, nil
 }
 
 func (w *HTMLWriter) Write(page types.MetaMarkdown, liveReload bool, fileTree *types.FileTree) error {
 	var cleanPath string
-	cleanPath = strings.TrimSuffix(utils.PathToSlug(page.RelativePath), ".md")
-	outputPath := filepath.Join("public", cleanPath+".html")
-
-	err := os.MkdirAll(filepath.Dir(outputPath), 0o755)
-	if err != nil {
+	cleanPath = utils.PageSlug(page.RelativePath)
+	outputPath := pageFile(w.cfg, "/"+cleanPath)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
 		return err
 	}
 
@@ -76,10 +116,16 @@ func (w *HTMLWriter) Write(page types.MetaMarkdown, liveReload bool, fileTree *t
 	outgoingHTML := RenderLinkList(page.OutgoingLinks)
 	backlinksHTML := RenderLinkList(page.Backlinks)
 	tocHTML := RenderTOC(page.TableOfContents)
-	tagsHTML := RenderTags(page.Tags)
+	tagsHTML := RenderTags(w.cfg, page.Lang, page.Tags)
+	strs := w.strings[page.Lang]
+	translations := pageTranslations(w.Translations, page)
 
-	graphData := BuildGraph(page)
-	graphHTML := RenderGraphView(graphData, currentPageURL)
+	var mostReferencedHTML string
+	if cleanPath == "index" {
+		mostReferencedHTML = RenderRankedLinkList(w.MostReferenced)
+	}
+
+	graphHTML := RenderGraphView(w.cfg, page, currentPageURL)
 	date := time.Now().Format("2006-01-02")
 
 	// modified -> created -> now
@@ -99,6 +145,11 @@ func (w *HTMLWriter) Write(page types.MetaMarkdown, liveReload bool, fileTree *t
 		}
 	}
 
+	socialImageURL, err := socialImage(w.cfg, w.cards, strs, page, outputPath)
+	if err != nil {
+		return fmt.Errorf("social card: %w", err)
+	}
+
 	pagefind := true
 	if v, ok := page.Frontmatter["pagefind"]; ok {
 		if b, ok := v.(bool); ok {
@@ -107,38 +158,55 @@ func (w *HTMLWriter) Write(page types.MetaMarkdown, liveReload bool, fileTree *t
 	}
 
 	data := PageData{
-		Name:          template.HTML(w.cfg.Site.Name),
-		Suffix:        template.HTML(w.cfg.Site.Suffix),
-		Title:         template.HTML(page.Title),
-		Tags:          template.HTML(tagsHTML),
-		WordCount:     template.HTML(strconv.Itoa(page.WordCount)),
-		ReadingTime:   template.HTML(strconv.Itoa(page.ReadingTime)),
-		Content:       template.HTML(page.HTML),
-		Explorer:      template.HTML(RenderExplorer(fileTree)),
-		Graph:         template.HTML(graphHTML),
-		Toc:           template.HTML(tocHTML),
-		OutgoingLinks: template.HTML(outgoingHTML),
-		Backlinks:     template.HTML(backlinksHTML),
-		Socials:       template.HTML(RenderSocials(w.cfg.Socials)),
-		HasKatex:      page.HasKatex,
-		HasMermaid:    page.HasMermaid,
-		HasTwitter:    strings.Contains(page.HTML, `blockquote class="twitter-tweet"`),
-		LiveReload:    liveReload,
-		CSSClasses:    parseCSSClasses(page.Frontmatter),
-		Description:   page.Description,
-		Keywords:      parseKeywords(page.Frontmatter),
-		Date:          template.HTML(date),
-		Pagefind:      pagefind,
-		Aliases:       template.HTML(strings.Join(parseAliases(page.Frontmatter), ", ")),
-	}
-
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return err
+		Name:           template.HTML(w.cfg.Site.Name),
+		Suffix:         template.HTML(w.cfg.Site.Suffix),
+		Title:          template.HTML(page.Title),
+		Tags:           template.HTML(tagsHTML),
+		WordCount:      template.HTML(strconv.Itoa(page.WordCount)),
+		ReadingTime:    template.HTML(strconv.Itoa(page.ReadingTime)),
+		Content:        template.HTML(page.HTML),
+		Explorer:       template.HTML(w.explorer(fileTree, page.Lang)),
+		Graph:          template.HTML(graphHTML),
+		Toc:            template.HTML(tocHTML),
+		OutgoingLinks:  template.HTML(outgoingHTML),
+		Backlinks:      template.HTML(backlinksHTML),
+		MostReferenced: template.HTML(mostReferencedHTML),
+		Socials:        template.HTML(RenderSocials(w.cfg.Socials)),
+		HasKatex:       page.HasKatex,
+		HasMermaid:     page.HasMermaid,
+		HasTwitter:     strings.Contains(page.HTML, `blockquote class="twitter-tweet"`),
+		LiveReload:     liveReload,
+		CSSClasses:     parseCSSClasses(page.Frontmatter),
+		Description:    page.Description,
+		Keywords:       parseKeywords(page.Frontmatter),
+		Date:           template.HTML(date),
+		Pagefind:       pagefind,
+		Aliases:        template.HTML(strings.Join(parseAliases(page.Frontmatter), ", ")),
+		Feeds:          renderFeedLinks(SiteFeeds(w.cfg, page.Lang)),
+		Canonical:      canonicalURL(w.cfg, currentPageURL),
+		SocialImage:    socialImageURL,
+		Lang:           page.Lang,
+		T:              strs,
+		Translations:   translations,
+		Alternates:     renderAlternateLinks(w.cfg, translations),
+		Fields:         page.Fields,
 	}
-	defer file.Close()
 
-	return w.tmpl.Execute(file, data)
+	return writeHTML(w.cfg, w.tmpl, outputPath, data)
+}
+
+func (w *HTMLWriter) explorer(fileTree *types.FileTree, lang string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.explorerTree != fileTree {
+		w.explorerHTML = make(map[string]string)
+		w.explorerTree = fileTree
+	}
+	if _, ok := w.explorerHTML[lang]; !ok {
+		w.explorerHTML[lang] = RenderExplorer(fileTree, lang)
+	}
+	return w.explorerHTML[lang]
 }
 
 func parseCSSClasses(front map[string]any) []string {
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"geode/internal/types"
+)
+
+// Format is part of every cache key. Bump it whenever the renderer starts
+// producing different output for the same note.
+const Format = "9"
+
+// Entry is everything the renderer produces for a note that does not depend
+// on the other pages of the site.
+type Entry struct {
+	HTML            string          `json:"html"`
+	TableOfContents []types.TocItem `json:"toc"`
+	OutgoingLinks   []types.Link    `json:"outgoingLinks"`
+	MissingLinks    []string        `json:"missingLinks"`
+	BlockIDs        []string        `json:"blockIds"`
+	Tags            []string        `json:"tags"`
+	Tasks           []types.Task    `json:"tasks"`
+	Fields          map[string]any  `json:"fields"`
+	HasKatex        bool            `json:"hasKatex"`
+	HasMermaid      bool            `json:"hasMermaid"`
+}
+
+// Store is an on-disk cache of rendered notes. A nil Store is valid and
+// caches nothing.
+type Store struct {
+	dir    string
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "pages"), 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(p)))
+		h.Write(size[:])
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *Store) Get(key string) (Entry, bool) {
+	if s == nil {
+		return Entry{}, false
+	}
+
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		s.misses.Add(1)
+		return Entry{}, false
+	}
+
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		s.misses.Add(1)
+		return Entry{}, false
+	}
+
+	s.hits.Add(1)
+	return e, true
+}
+
+func (s *Store) Put(key string, e Entry) error {
+	if s == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Stats returns the number of hits and misses since the store was opened.
+func (s *Store) Stats() (hits, misses int64) {
+	if s == nil {
+		return 0, 0
+	}
+	return s.hits.Load(), s.misses.Load()
+}
+
+func (s *Store) path(key string) string {
+	return filepath.Join(s.dir, "pages", key[:2], key+".json")
+}
@@ -0,0 +1,269 @@
+package canvas
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Ext is the file extension of JSON Canvas files.
+const Ext = ".canvas"
+
+// Canvas is a JSON Canvas document, see https://jsoncanvas.org.
+type Canvas struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+
+	// Publish opts the canvas in when build.mode is explicit. It is not part
+	// of JSON Canvas, and other readers ignore it.
+	Publish bool `json:"publish"`
+}
+
+type Node struct {
+	ID     string  `json:"id"`
+	Type   string  `json:"type"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Color  string  `json:"color"`
+
+	Text    string `json:"text"`
+	File    string `json:"file"`
+	Subpath string `json:"subpath"`
+	URL     string `json:"url"`
+	Label   string `json:"label"`
+}
+
+type Edge struct {
+	ID       string `json:"id"`
+	FromNode string `json:"fromNode"`
+	FromSide string `json:"fromSide"`
+	FromEnd  string `json:"fromEnd"`
+	ToNode   string `json:"toNode"`
+	ToSide   string `json:"toSide"`
+	ToEnd    string `json:"toEnd"`
+	Color    string `json:"color"`
+	Label    string `json:"label"`
+}
+
+// Load reads and parses the canvas at path.
+func Load(path string) (*Canvas, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+func Parse(data []byte) (*Canvas, error) {
+	var c Canvas
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse canvas: %w", err)
+	}
+	return &c, nil
+}
+
+// padding is the space kept around the nodes, in canvas pixels.
+const padding = 40
+
+// Render draws the canvas as positioned HTML nodes over an SVG of its edges.
+// content holds the inner HTML of the text, file and link nodes by id.
+// Groups are drawn first, then the edges, then the other nodes in the order
+// of the file, which is their z-order.
+func Render(c *Canvas, content map[string]string) string {
+	if len(c.Nodes) == 0 {
+		return `<div class="canvas canvas-empty"></div>` + "\n"
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, n := range c.Nodes {
+		minX = math.Min(minX, n.X)
+		minY = math.Min(minY, n.Y)
+		maxX = math.Max(maxX, n.X+n.Width)
+		maxY = math.Max(maxY, n.Y+n.Height)
+	}
+	ox, oy := padding-minX, padding-minY
+	width, height := maxX-minX+2*padding, maxY-minY+2*padding
+
+	var b strings.Builder
+	b.WriteString(`<div class="canvas">` + "\n")
+	fmt.Fprintf(&b, `<div class="canvas-board" style="width: %spx; height: %spx">`+"\n", num(width), num(height))
+
+	for _, n := range c.Nodes {
+		if n.Type == "group" {
+			writeNode(&b, n, ox, oy, "")
+		}
+	}
+
+	writeEdges(&b, c, ox, oy, width, height)
+
+	for _, n := range c.Nodes {
+		if n.Type != "group" {
+			writeNode(&b, n, ox, oy, content[n.ID])
+		}
+	}
+
+	b.WriteString("</div>\n</div>\n")
+	return b.String()
+}
+
+func writeNode(b *strings.Builder, n Node, ox, oy float64, inner string) {
+	class, style := colorAttrs(n.Color)
+	fmt.Fprintf(b, `<div class="canvas-node canvas-node-%s%s" style="left: %spx; top: %spx; width: %spx; height: %spx%s">`,
+		html.EscapeString(n.Type), class, num(n.X+ox), num(n.Y+oy), num(n.Width), num(n.Height), style)
+
+	if n.Type == "group" {
+		if n.Label != "" {
+			fmt.Fprintf(b, `<div class="canvas-group-label">%s</div>`, html.EscapeString(n.Label))
+		}
+	} else {
+		b.WriteString(`<div class="canvas-node-content">` + "\n")
+		b.WriteString(inner)
+		b.WriteString("</div>")
+	}
+	b.WriteString("</div>\n")
+}
+
+type point struct{ x, y float64 }
+
+func (p point) add(q point, k float64) point {
+	return point{p.x + q.x*k, p.y + q.y*k}
+}
+
+var sideNormals = map[string]point{
+	"top":    {0, -1},
+	"bottom": {0, 1},
+	"left":   {-1, 0},
+	"right":  {1, 0},
+}
+
+func writeEdges(b *strings.Builder, c *Canvas, ox, oy, width, height float64) {
+	if len(c.Edges) == 0 {
+		return
+	}
+
+	nodes := make(map[string]Node, len(c.Nodes))
+	for _, n := range c.Nodes {
+		n.X += ox
+		n.Y += oy
+		nodes[n.ID] = n
+	}
+
+	fmt.Fprintf(b, `<svg class="canvas-edges" width="%s" height="%s" viewBox="0 0 %s %s" aria-hidden="true">`+"\n",
+		num(width), num(height), num(width), num(height))
+
+	for _, e := range c.Edges {
+		from, ok1 := nodes[e.FromNode]
+		to, ok2 := nodes[e.ToNode]
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		fromSide, toSide := e.FromSide, e.ToSide
+		if _, ok := sideNormals[fromSide]; !ok {
+			fromSide = facingSide(from, to)
+		}
+		if _, ok := sideNormals[toSide]; !ok {
+			toSide = facingSide(to, from)
+		}
+
+		p1, n1 := anchor(from, fromSide), sideNormals[fromSide]
+		p2, n2 := anchor(to, toSide), sideNormals[toSide]
+
+		// Control points leave each side along its normal, like Obsidian's
+		// curves.
+		k := math.Min(math.Max(math.Hypot(p2.x-p1.x, p2.y-p1.y)/2, 40), 150)
+		c1, c2 := p1.add(n1, k), p2.add(n2, k)
+
+		class, style := colorAttrs(e.Color)
+		if style != "" {
+			style = ` style="` + strings.TrimPrefix(style, "; ") + `"`
+		}
+		fmt.Fprintf(b, `<g class="canvas-edge%s"%s>`, class, style)
+		fmt.Fprintf(b, `<path d="M %s %s C %s %s, %s %s, %s %s" />`,
+			num(p1.x), num(p1.y), num(c1.x), num(c1.y), num(c2.x), num(c2.y), num(p2.x), num(p2.y))
+
+		// The end of the edge defaults to an arrow, its start to nothing.
+		if e.ToEnd != "none" {
+			writeArrow(b, p2, n2)
+		}
+		if e.FromEnd == "arrow" {
+			writeArrow(b, p1, n1)
+		}
+
+		if e.Label != "" {
+			mid := point{
+				0.125*p1.x + 0.375*c1.x + 0.375*c2.x + 0.125*p2.x,
+				0.125*p1.y + 0.375*c1.y + 0.375*c2.y + 0.125*p2.y,
+			}
+			fmt.Fprintf(b, `<text x="%s" y="%s">%s</text>`, num(mid.x), num(mid.y), html.EscapeString(e.Label))
+		}
+		b.WriteString("</g>\n")
+	}
+
+	b.WriteString("</svg>\n")
+}
+
+// facingSide returns the side of n that faces other.
+func facingSide(n, other Node) string {
+	dx := (other.X + other.Width/2) - (n.X + n.Width/2)
+	dy := (other.Y + other.Height/2) - (n.Y + n.Height/2)
+	if math.Abs(dx) > math.Abs(dy) {
+		if dx > 0 {
+			return "right"
+		}
+		return "left"
+	}
+	if dy > 0 {
+		return "bottom"
+	}
+	return "top"
+}
+
+func anchor(n Node, side string) point {
+	switch side {
+	case "top":
+		return point{n.X + n.Width/2, n.Y}
+	case "bottom":
+		return point{n.X + n.Width/2, n.Y + n.Height}
+	case "left":
+		return point{n.X, n.Y + n.Height/2}
+	}
+	return point{n.X + n.Width, n.Y + n.Height/2}
+}
+
+// writeArrow draws an arrowhead with its tip at p, pointing into the side
+// with the given normal.
+func writeArrow(b *strings.Builder, p, normal point) {
+	const length, halfWidth = 12, 6
+	base := p.add(normal, length)
+	perp := point{-normal.y, normal.x}
+	l, r := base.add(perp, halfWidth), base.add(perp, -halfWidth)
+	fmt.Fprintf(b, `<polygon points="%s,%s %s,%s %s,%s" />`,
+		num(p.x), num(p.y), num(l.x), num(l.y), num(r.x), num(r.y))
+}
+
+var hexColorReg = regexp.MustCompile(`^#[0-9a-fA-F]{3}([0-9a-fA-F]{3})?$`)
+
+// colorAttrs returns the class of a preset color ("1" to "6") or the style
+// of a hex color.
+func colorAttrs(color string) (class, style string) {
+	switch {
+	case len(color) == 1 && color >= "1" && color <= "6":
+		return " canvas-color-" + color, ""
+	case hexColorReg.MatchString(color):
+		return "", "; --canvas-color: " + color
+	}
+	return "", ""
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,18 +15,56 @@ type Social struct {
 	Link  string `yaml:"link"`
 }
 
+type Language struct {
+	Code string `yaml:"code"`
+	Name string `yaml:"name"`
+}
+
 type Config struct {
 	Site struct {
-		Name    string `yaml:"name"`
-		Suffix  string `yaml:"suffix"`
-		BaseURL string `yaml:"base_url"`
+		Name     string `yaml:"name"`
+		Suffix   string `yaml:"suffix"`
+		BaseURL  string `yaml:"base_url"`
+		BasePath string `yaml:"base_path"`
 	} `yaml:"site"`
 
 	Build struct {
-		Output string `yaml:"output"`
-		Mode   string `yaml:"mode"`
+		Output   string `yaml:"output"`
+		Mode     string `yaml:"mode"`
+		Jobs     int    `yaml:"jobs"`
+		Cache    string `yaml:"cache"`
+		URLStyle string `yaml:"url_style"`
 	} `yaml:"build"`
 
+	Graph struct {
+		Depth          int      `yaml:"depth"`
+		Tags           bool     `yaml:"tags"`
+		HideOrphans    bool     `yaml:"hide_orphans"`
+		ExcludeFolders []string `yaml:"exclude_folders"`
+		ExcludeTags    []string `yaml:"exclude_tags"`
+		Links          string   `yaml:"links"`
+	} `yaml:"graph"`
+
+	Feeds struct {
+		Limit       int  `yaml:"limit"`
+		FullContent bool `yaml:"full_content"`
+		Tags        bool `yaml:"tags"`
+		Folders     bool `yaml:"folders"`
+	} `yaml:"feeds"`
+
+	Folders struct {
+		Sort string `yaml:"sort"`
+	} `yaml:"folders"`
+
+	Markdown struct {
+		Embeds string `yaml:"embeds"`
+	} `yaml:"markdown"`
+
+	I18n struct {
+		Default   string     `yaml:"default"`
+		Languages []Language `yaml:"languages"`
+	} `yaml:"i18n"`
+
 	Theme string `yaml:"theme"`
 
 	IgnorePatterns []string `yaml:"ignorePatterns"`
@@ -31,13 +72,42 @@ type Config struct {
 	Socials []Social `yaml:"socials"`
 }
 
-const ConfigFile = "geode.config.yaml"
+const (
+	ConfigFile      = "geode.config.yaml"
+	DefaultCacheDir = ".geode-cache"
+)
+
+const DefaultFeedLimit = 20
+
+const DefaultLanguage = "en"
 
 const (
 	ModeDraft    = "draft"
 	ModeExplicit = "explicit"
 )
 
+const (
+	URLStyleHTML      = "html"
+	URLStyleDirectory = "directory"
+)
+
+const (
+	GraphLinksBoth      = "both"
+	GraphLinksOutgoing  = "outgoing"
+	GraphLinksBacklinks = "backlinks"
+)
+
+const (
+	FolderSortTitle    = "title"
+	FolderSortCreated  = "created"
+	FolderSortModified = "modified"
+)
+
+const (
+	EmbedsFramed = "framed"
+	EmbedsInline = "inline"
+)
+
 func Load() (*Config, error) {
 	data, err := os.ReadFile(ConfigFile)
 	if err != nil {
@@ -57,6 +127,54 @@ func Load() (*Config, error) {
 		cfg.Theme = "default"
 	}
 
+	// The site lives under the path of base_url unless base_path says
+	// otherwise.
+	if cfg.Site.BasePath == "" {
+		u, _ := url.Parse(cfg.Site.BaseURL)
+		cfg.Site.BasePath = u.Path
+	}
+	cfg.Site.BasePath = normalizeBasePath(cfg.Site.BasePath)
+
+	if cfg.Build.Cache == "" {
+		cfg.Build.Cache = DefaultCacheDir
+	}
+
+	if cfg.Build.URLStyle == "" {
+		cfg.Build.URLStyle = URLStyleHTML
+	}
+
+	if cfg.Graph.Depth == 0 {
+		cfg.Graph.Depth = 1
+	}
+
+	if cfg.Graph.Links == "" {
+		cfg.Graph.Links = GraphLinksBoth
+	}
+
+	if cfg.Feeds.Limit == 0 {
+		cfg.Feeds.Limit = DefaultFeedLimit
+	}
+
+	if cfg.Folders.Sort == "" {
+		cfg.Folders.Sort = FolderSortTitle
+	}
+
+	if cfg.Markdown.Embeds == "" {
+		cfg.Markdown.Embeds = EmbedsFramed
+	}
+
+	if cfg.I18n.Default == "" {
+		cfg.I18n.Default = DefaultLanguage
+		if len(cfg.I18n.Languages) > 0 {
+			cfg.I18n.Default = cfg.I18n.Languages[0].Code
+		}
+	}
+	for i, lang := range cfg.I18n.Languages {
+		if lang.Name == "" {
+			cfg.I18n.Languages[i].Name = lang.Code
+		}
+	}
+
 	return &cfg, nil
 }
 
@@ -69,10 +187,18 @@ func validate(cfg *Config) error {
 		return errors.New("site.base_url is required")
 	}
 
+	if u, err := url.Parse(cfg.Site.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("site.base_url must be an absolute URL, got %q", cfg.Site.BaseURL)
+	}
+
 	if cfg.Build.Output == "" {
 		return errors.New("build.output is required")
 	}
 
+	if cfg.Build.Jobs < 0 {
+		return errors.New("build.jobs must not be negative")
+	}
+
 	switch cfg.Build.Mode {
 	case ModeDraft, ModeExplicit:
 	// valid
@@ -80,5 +206,115 @@ func validate(cfg *Config) error {
 		return errors.New(`build.mode must be either "draft" or "explicit"`)
 	}
 
+	switch cfg.Build.URLStyle {
+	case "", URLStyleHTML, URLStyleDirectory:
+	// valid
+	default:
+		return errors.New(`build.url_style must be either "html" or "directory"`)
+	}
+
+	if cfg.Graph.Depth < 0 {
+		return errors.New("graph.depth must not be negative")
+	}
+
+	switch cfg.Graph.Links {
+	case "", GraphLinksBoth, GraphLinksOutgoing, GraphLinksBacklinks:
+	// valid
+	default:
+		return errors.New(`graph.links must be "both", "outgoing" or "backlinks"`)
+	}
+
+	if cfg.Feeds.Limit < 0 {
+		return errors.New("feeds.limit must not be negative")
+	}
+
+	switch cfg.Folders.Sort {
+	case "", FolderSortTitle, FolderSortCreated, FolderSortModified:
+	// valid
+	default:
+		return errors.New(`folders.sort must be "title", "created" or "modified"`)
+	}
+
+	switch cfg.Markdown.Embeds {
+	case "", EmbedsFramed, EmbedsInline:
+	// valid
+	default:
+		return errors.New(`markdown.embeds must be either "framed" or "inline"`)
+	}
+
+	seen := make(map[string]bool, len(cfg.I18n.Languages))
+	for _, lang := range cfg.I18n.Languages {
+		if lang.Code == "" {
+			return errors.New("i18n.languages entries need a code")
+		}
+		if seen[lang.Code] {
+			return fmt.Errorf("i18n.languages lists %q twice", lang.Code)
+		}
+		seen[lang.Code] = true
+	}
+	if cfg.I18n.Default != "" && len(seen) > 0 && !seen[cfg.I18n.Default] {
+		return fmt.Errorf("i18n.default %q is not in i18n.languages", cfg.I18n.Default)
+	}
+
 	return nil
 }
+
+// normalizeBasePath turns "wiki/" or "/wiki" into "/wiki"; the root is "".
+func normalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// SiteURL returns the absolute URL of the site root, without a trailing
+// slash: the scheme and host of site.base_url followed by the base path.
+func (c *Config) SiteURL() string {
+	u, err := url.Parse(c.Site.BaseURL)
+	if err != nil {
+		return strings.TrimSuffix(c.Site.BaseURL, "/")
+	}
+	return u.Scheme + "://" + u.Host + c.Site.BasePath
+}
+
+// Multilingual reports whether the site is published in more than one
+// language.
+func (c *Config) Multilingual() bool {
+	return len(c.I18n.Languages) > 1
+}
+
+// LanguageCodes returns the codes of the site languages in the order of
+// i18n.languages, or only the default language.
+func (c *Config) LanguageCodes() []string {
+	if len(c.I18n.Languages) == 0 {
+		return []string{c.I18n.Default}
+	}
+	codes := make([]string, len(c.I18n.Languages))
+	for i, lang := range c.I18n.Languages {
+		codes[i] = lang.Code
+	}
+	return codes
+}
+
+// Language returns the language with the given code.
+func (c *Config) Language(code string) (Language, bool) {
+	for _, lang := range c.I18n.Languages {
+		if lang.Code == code {
+			return lang, true
+		}
+	}
+	if code == c.I18n.Default {
+		return Language{Code: code, Name: code}, true
+	}
+	return Language{}, false
+}
+
+// LangPrefix returns the path under which the listings of a language are
+// written: "" for the default language and "/<code>" for the others.
+func (c *Config) LangPrefix(code string) string {
+	if code == "" || code == c.I18n.Default {
+		return ""
+	}
+	return "/" + code
+}
@@ -3,6 +3,8 @@ package content
 import (
 	"bufio"
 	"fmt"
+	"geode/internal/bases"
+	"geode/internal/canvas"
 	"geode/internal/config"
 	"io/fs"
 	"log"
@@ -19,6 +21,13 @@ type FileEntry struct {
 	Size         int64
 	IsMarkdown   bool
 	IsAsset      bool
+	IsBase       bool
+	IsCanvas     bool
+
+	// Title and Aliases come from the note frontmatter and are only set
+	// once the entry went through FilterEntries.
+	Title   string
+	Aliases []string
 }
 
 func GetAllMarkdownAndAssets(srcDir string, cfg *config.Config) ([]FileEntry, error) {
@@ -43,9 +52,11 @@ func GetAllMarkdownAndAssets(srcDir string, cfg *config.Config) ([]FileEntry, er
 		ext := strings.ToLower(filepath.Ext(path))
 
 		isMarkdown := ext == ".md"
-		isAsset := isAssetFile(ext)
+		isAsset := IsAssetFile(ext)
+		isBase := ext == bases.Ext
+		isCanvas := ext == canvas.Ext
 
-		if !isMarkdown && !isAsset {
+		if !isMarkdown && !isAsset && !isBase && !isCanvas {
 			return nil
 		}
 
@@ -65,6 +76,8 @@ func GetAllMarkdownAndAssets(srcDir string, cfg *config.Config) ([]FileEntry, er
 			Size:         info.Size(),
 			IsMarkdown:   isMarkdown,
 			IsAsset:      isAsset,
+			IsBase:       isBase,
+			IsCanvas:     isCanvas,
 		})
 
 		return nil
@@ -111,7 +124,7 @@ var assetExt = map[string]struct{}{
 	".gif": {}, ".svg": {}, ".webp": {},
 }
 
-func isAssetFile(ext string) bool {
+func IsAssetFile(ext string) bool {
 	_, ok := assetExt[ext]
 	return ok
 }
@@ -167,6 +180,23 @@ func FilterEntries(entries []FileEntry, cfg *config.Config) []FileEntry {
 	out := make([]FileEntry, 0, len(entries))
 
 	for _, e := range entries {
+		// Canvases have no frontmatter: they are always published in draft
+		// mode and need a top-level "publish": true in explicit mode.
+		if e.IsCanvas {
+			if cfg.Build.Mode == config.ModeExplicit {
+				c, err := canvas.Load(e.Path)
+				if err != nil {
+					log.Printf("canvas error: %s (%v)", e.Path, err)
+					continue
+				}
+				if !c.Publish {
+					continue
+				}
+			}
+			out = append(out, e)
+			continue
+		}
+
 		if !e.IsMarkdown {
 			out = append(out, e)
 			continue
@@ -184,6 +214,9 @@ func FilterEntries(entries []FileEntry, cfg *config.Config) []FileEntry {
 			}
 		}
 
+		e.Title = meta.Title
+		e.Aliases = meta.Aliases
+
 		switch cfg.Build.Mode {
 
 		case config.ModeDraft:
@@ -0,0 +1,309 @@
+package ogimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateFile is the card template of a theme, relative to the theme folder.
+const TemplateFile = "og.yaml"
+
+// Card is the content of a social card. ReadingTime is shown as is, for
+// example "3 min read".
+type Card struct {
+	SiteName    string
+	Title       string
+	Tags        []string
+	ReadingTime string
+}
+
+type textStyle struct {
+	Size     float64 `yaml:"size"`
+	MaxLines int     `yaml:"max_lines"`
+	Show     *bool   `yaml:"show"`
+}
+
+func (s textStyle) shown() bool {
+	return s.Show == nil || *s.Show
+}
+
+// Template is the layout of the cards of a theme, read from og.yaml.
+type Template struct {
+	Width      int    `yaml:"width"`
+	Height     int    `yaml:"height"`
+	Padding    int    `yaml:"padding"`
+	Background string `yaml:"background"`
+	Foreground string `yaml:"foreground"`
+	Muted      string `yaml:"muted"`
+	Accent     string `yaml:"accent"`
+
+	Fonts struct {
+		Regular string `yaml:"regular"`
+		Bold    string `yaml:"bold"`
+	} `yaml:"fonts"`
+
+	SiteName    textStyle `yaml:"site_name"`
+	Title       textStyle `yaml:"title"`
+	Tags        textStyle `yaml:"tags"`
+	ReadingTime textStyle `yaml:"reading_time"`
+}
+
+// Renderer draws cards from a theme template. It is safe for concurrent use.
+type Renderer struct {
+	tmpl    Template
+	regular *opentype.Font
+	bold    *opentype.Font
+
+	background, foreground, muted, accent color.Color
+}
+
+// Load reads the card template of the theme in themeDir. Themes without an
+// og.yaml have no cards and get a nil Renderer.
+func Load(themeDir string) (*Renderer, error) {
+	data, err := os.ReadFile(filepath.Join(themeDir, TemplateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := Template{
+		Width:      1200,
+		Height:     630,
+		Padding:    80,
+		Background: "#ffffff",
+		Foreground: "#1f2328",
+		Muted:      "#656d76",
+		Accent:     "#0969da",
+	}
+	tmpl.SiteName.Size = 36
+	tmpl.Title.Size = 72
+	tmpl.Title.MaxLines = 3
+	tmpl.Tags.Size = 32
+	tmpl.ReadingTime.Size = 32
+	if err := yaml.Unmarshal(data, &tmpl); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", TemplateFile, err)
+	}
+
+	r := &Renderer{tmpl: tmpl}
+	if r.regular, err = loadFont(themeDir, tmpl.Fonts.Regular); err != nil {
+		return nil, err
+	}
+	if r.bold, err = loadFont(themeDir, tmpl.Fonts.Bold); err != nil {
+		return nil, err
+	}
+
+	for _, c := range []struct {
+		dst *color.Color
+		hex string
+	}{
+		{&r.background, tmpl.Background},
+		{&r.foreground, tmpl.Foreground},
+		{&r.muted, tmpl.Muted},
+		{&r.accent, tmpl.Accent},
+	} {
+		if *c.dst, err = parseHex(c.hex); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", TemplateFile, err)
+		}
+	}
+
+	return r, nil
+}
+
+func loadFont(themeDir, name string) (*opentype.Font, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%s: fonts.regular and fonts.bold are required", TemplateFile)
+	}
+
+	data, err := os.ReadFile(filepath.Join(themeDir, name))
+	if err != nil {
+		return nil, fmt.Errorf("read font: %w", err)
+	}
+
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse font %s: %w", name, err)
+	}
+	return f, nil
+}
+
+func parseHex(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// Render writes card as a PNG image.
+func (r *Renderer) Render(w io.Writer, card Card) error {
+	t := r.tmpl
+	img := image.NewRGBA(image.Rect(0, 0, t.Width, t.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(r.background), image.Point{}, draw.Src)
+
+	// accent bar along the top edge
+	draw.Draw(img, image.Rect(0, 0, t.Width, 12), image.NewUniform(r.accent), image.Point{}, draw.Src)
+
+	// Faces cache glyphs and are not safe for concurrent use, so every card
+	// gets its own.
+	siteFace, err := r.face(r.bold, t.SiteName.Size)
+	if err != nil {
+		return err
+	}
+	defer siteFace.Close()
+	titleFace, err := r.face(r.bold, t.Title.Size)
+	if err != nil {
+		return err
+	}
+	defer titleFace.Close()
+	tagFace, err := r.face(r.regular, t.Tags.Size)
+	if err != nil {
+		return err
+	}
+	defer tagFace.Close()
+	timeFace, err := r.face(r.regular, t.ReadingTime.Size)
+	if err != nil {
+		return err
+	}
+	defer timeFace.Close()
+
+	width := t.Width - 2*t.Padding
+	y := t.Padding
+
+	if t.SiteName.shown() && card.SiteName != "" {
+		y += ascent(siteFace)
+		drawText(img, siteFace, r.accent, t.Padding, y, truncate(siteFace, card.SiteName, width))
+		y += int(t.SiteName.Size)
+	}
+
+	if t.Title.shown() {
+		lineHeight := int(t.Title.Size * 1.2)
+		y += lineHeight - int(t.Title.Size) + ascent(titleFace)
+		for _, line := range wrap(titleFace, card.Title, width, t.Title.MaxLines) {
+			drawText(img, titleFace, r.foreground, t.Padding, y, line)
+			y += lineHeight
+		}
+	}
+
+	bottom := t.Height - t.Padding
+	tagsWidth := width
+	if t.ReadingTime.shown() && card.ReadingTime != "" {
+		label := card.ReadingTime
+		labelWidth := font.MeasureString(timeFace, label).Ceil()
+		drawText(img, timeFace, r.muted, t.Padding+width-labelWidth, bottom, label)
+		tagsWidth -= labelWidth + t.Padding/2
+	}
+
+	if t.Tags.shown() && len(card.Tags) > 0 {
+		tags := make([]string, len(card.Tags))
+		for i, tag := range card.Tags {
+			tags[i] = "#" + tag
+		}
+		drawText(img, tagFace, r.accent, t.Padding, bottom, truncate(tagFace, strings.Join(tags, "  "), tagsWidth))
+	}
+
+	return png.Encode(w, img)
+}
+
+func (r *Renderer) face(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+func ascent(face font.Face) int {
+	return face.Metrics().Ascent.Ceil()
+}
+
+func drawText(dst draw.Image, face font.Face, c color.Color, x, y int, s string) {
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+// wrap breaks s into lines no wider than width. Words longer than a line are
+// broken, and the last of maxLines lines ends with an ellipsis when the text
+// does not fit.
+func wrap(face font.Face, s string, width, maxLines int) []string {
+	fits := func(line string) bool {
+		return font.MeasureString(face, line).Ceil() <= width
+	}
+
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if fits(candidate) {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = word
+		for !fits(line) {
+			head := truncate(face, line, width)
+			head = strings.TrimSuffix(head, "…")
+			if head == "" {
+				break
+			}
+			lines = append(lines, head)
+			line = strings.TrimPrefix(line, head)
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if maxLines > 0 && len(lines) > maxLines {
+		last := strings.Join(lines[maxLines-1:], " ")
+		lines = append(lines[:maxLines-1], truncate(face, last+"…", width))
+	}
+	return lines
+}
+
+// truncate shortens s with an ellipsis so that it is no wider than width.
+func truncate(face font.Face, s string, width int) string {
+	if font.MeasureString(face, s).Ceil() <= width {
+		return s
+	}
+
+	runes := []rune(strings.TrimSuffix(s, "…"))
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimRight(string(runes), " ") + "…"
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			return candidate
+		}
+	}
+	return ""
+}
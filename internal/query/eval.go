@@ -0,0 +1,559 @@
+package query
+
+import (
+	"fmt"
+	"geode/internal/types"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Env is what a query runs against: every published page, the page holding
+// the query, and the resolver of wikilinks to page URLs.
+type Env struct {
+	Pages   []types.MetaMarkdown
+	This    *types.MetaMarkdown
+	Resolve func(target string) (string, bool)
+}
+
+// pageRef is a page as a value, such as `this`.
+type pageRef struct{ page *types.MetaMarkdown }
+
+// fileRef is the `file` of a page, its metadata besides the frontmatter.
+type fileRef struct{ page *types.MetaMarkdown }
+
+type linkValue struct{ url, text string }
+
+// Run returns the pages matching the query, sorted and limited.
+func (q *Query) Run(env Env) []*types.MetaMarkdown {
+	var rows []*types.MetaMarkdown
+	for i := range env.Pages {
+		page := &env.Pages[i]
+		if q.From != nil && !env.match(q.From, page) {
+			continue
+		}
+		if q.Where != nil && !truthy(env.eval(q.Where, page)) {
+			continue
+		}
+		rows = append(rows, page)
+	}
+
+	if len(q.Sort) > 0 {
+		keys := make(map[*types.MetaMarkdown][]any, len(rows))
+		for _, row := range rows {
+			for _, s := range q.Sort {
+				keys[row] = append(keys[row], env.eval(s.x, row))
+			}
+		}
+		slices.SortStableFunc(rows, func(a, b *types.MetaMarkdown) int {
+			for i, s := range q.Sort {
+				c := order(keys[a][i], keys[b][i], s.Desc)
+				if c != 0 {
+					return c
+				}
+			}
+			return 0
+		})
+	}
+
+	if q.Limit > 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+	}
+	return rows
+}
+
+func (env Env) match(s source, page *types.MetaMarkdown) bool {
+	switch s := s.(type) {
+	case tagSource:
+		return hasTag(page, s.tag)
+
+	case folderSource:
+		rel := filepath.ToSlash(page.RelativePath)
+		return s.folder == "" ||
+			strings.HasPrefix(rel, s.folder+"/") ||
+			strings.TrimSuffix(rel, path.Ext(rel)) == s.folder
+
+	case linkSource:
+		url, ok := env.resolve(s.target)
+		if !ok {
+			return false
+		}
+		for _, link := range page.OutgoingLinks {
+			if stripFragment(link.URL) == url {
+				return true
+			}
+		}
+		return false
+
+	case notSource:
+		return !env.match(s.x, page)
+
+	case binarySource:
+		if s.op == "AND" {
+			return env.match(s.x, page) && env.match(s.y, page)
+		}
+		return env.match(s.x, page) || env.match(s.y, page)
+	}
+	return false
+}
+
+// hasTag reports whether page has tag or one of its nested tags.
+func hasTag(page *types.MetaMarkdown, tag string) bool {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	for _, t := range page.Tags {
+		t = strings.ToLower(t)
+		if t == tag || strings.HasPrefix(t, tag+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (env Env) resolve(target string) (string, bool) {
+	if env.Resolve == nil {
+		return "", false
+	}
+	url, ok := env.Resolve(target)
+	return stripFragment(url), ok
+}
+
+func stripFragment(url string) string {
+	if i := strings.IndexByte(url, '#'); i >= 0 {
+		return url[:i]
+	}
+	return url
+}
+
+// eval evaluates x for page. Values are nil, string, float64, bool,
+// time.Time, []any, map[string]any, linkValue, pageRef and fileRef. Missing
+// fields and operations on the wrong types give nil rather than an error, so
+// a WHERE on a field some pages lack leaves those pages out.
+func (env Env) eval(x expr, page *types.MetaMarkdown) any {
+	switch x := x.(type) {
+	case literal:
+		return x.value
+
+	case ident:
+		switch x.name {
+		case "file":
+			return fileRef{page}
+		case "this":
+			if env.This == nil {
+				return nil
+			}
+			return pageRef{env.This}
+		}
+		return field(page, x.name)
+
+	case member:
+		switch recv := env.eval(x.x, page).(type) {
+		case pageRef:
+			if x.name == "file" {
+				return fileRef{recv.page}
+			}
+			return field(recv.page, x.name)
+		case fileRef:
+			return fileField(recv.page, x.name)
+		case map[string]any:
+			return normalize(recv[x.name])
+		}
+		return nil
+
+	case linkLit:
+		target, text, _ := strings.Cut(x.target, "|")
+		url, _ := env.resolve(target)
+		if text == "" {
+			text = target
+		}
+		return linkValue{url: url, text: text}
+
+	case call:
+		args := make([]any, len(x.args))
+		for i, arg := range x.args {
+			args[i] = env.eval(arg, page)
+		}
+		return callFunc(x.name, args)
+
+	case unary:
+		v := env.eval(x.x, page)
+		if x.op == "!" {
+			return !truthy(v)
+		}
+		if n, ok := v.(float64); ok {
+			return -n
+		}
+		return nil
+
+	case binary:
+		return env.binary(x, page)
+	}
+	return nil
+}
+
+// field returns a frontmatter or inline field of page. title, tags, link and
+// folder are the ones of the page, so they work for notes without
+// frontmatter.
+func field(page *types.MetaMarkdown, name string) any {
+	switch name {
+	case "title":
+		return page.Title
+	case "tags":
+		return fileField(page, "tags")
+	case "link":
+		return fileField(page, "link")
+	case "folder":
+		return fileField(page, "folder")
+	}
+
+	props := page.Fields
+	if props == nil {
+		props = page.Frontmatter
+	}
+	if v, ok := props[name]; ok {
+		return normalize(v)
+	}
+	for key, v := range props {
+		if strings.EqualFold(key, name) {
+			return normalize(v)
+		}
+	}
+	return nil
+}
+
+func fileField(page *types.MetaMarkdown, name string) any {
+	rel := filepath.ToSlash(page.RelativePath)
+	switch name {
+	case "name":
+		return strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	case "path":
+		return rel
+	case "folder":
+		if dir := path.Dir(rel); dir != "." {
+			return dir
+		}
+		return ""
+	case "ext":
+		return strings.TrimPrefix(path.Ext(rel), ".")
+	case "title":
+		return page.Title
+	case "link":
+		return linkValue{url: page.Link, text: page.Title}
+	case "tags":
+		tags := make([]any, len(page.Tags))
+		for i, t := range page.Tags {
+			tags[i] = t
+		}
+		return tags
+	case "frontmatter":
+		return page.Frontmatter
+	}
+	return nil
+}
+
+// normalize turns YAML numbers into float64, the only number type of
+// queries.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	case []string:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = item
+		}
+		return out
+	}
+	return v
+}
+
+func (env Env) binary(x binary, page *types.MetaMarkdown) any {
+	a := env.eval(x.x, page)
+	switch x.op {
+	case "AND":
+		return truthy(a) && truthy(env.eval(x.y, page))
+	case "OR":
+		return truthy(a) || truthy(env.eval(x.y, page))
+	}
+
+	b := env.eval(x.y, page)
+	switch x.op {
+	case "=":
+		return equal(a, b)
+	case "!=":
+		return !equal(a, b)
+	case "<", ">", "<=", ">=":
+		c, ok := compare(a, b)
+		if !ok {
+			return false
+		}
+		switch x.op {
+		case "<":
+			return c < 0
+		case ">":
+			return c > 0
+		case "<=":
+			return c <= 0
+		}
+		return c >= 0
+	}
+
+	if x.op == "+" {
+		if s, ok := a.(string); ok {
+			return s + toString(b)
+		}
+	}
+	m, ok1 := a.(float64)
+	n, ok2 := b.(float64)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	switch x.op {
+	case "+":
+		return m + n
+	case "-":
+		return m - n
+	case "*":
+		return m * n
+	case "/":
+		if n == 0 {
+			return nil
+		}
+		return m / n
+	}
+	return nil
+}
+
+func callFunc(name string, args []any) any {
+	switch name {
+	case "contains":
+		switch v := args[0].(type) {
+		case []any:
+			for _, item := range v {
+				if equal(item, args[1]) {
+					return true
+				}
+			}
+			return false
+		case string:
+			return strings.Contains(v, toString(args[1]))
+		}
+		return false
+
+	case "date":
+		if t, ok := toTime(args[0]); ok {
+			return t
+		}
+		return nil
+
+	case "default":
+		if args[0] == nil {
+			return args[1]
+		}
+		return args[0]
+
+	case "length":
+		switch v := args[0].(type) {
+		case []any:
+			return float64(len(v))
+		case string:
+			return float64(len([]rune(v)))
+		case nil:
+			return float64(0)
+		}
+		return nil
+
+	case "lower":
+		if s, ok := args[0].(string); ok {
+			return strings.ToLower(s)
+		}
+		return nil
+	}
+	return nil
+}
+
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if l, ok := a.(linkValue); ok {
+		if m, ok := b.(linkValue); ok {
+			return l.url != "" && l.url == m.url
+		}
+	}
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	return false
+}
+
+// compare orders two values of the same kind. Dates compare with strings
+// holding a date, as frontmatter dates are often plain strings.
+func compare(a, b any) (int, bool) {
+	ta, okA := a.(time.Time)
+	tb, okB := b.(time.Time)
+	if okA || okB {
+		if !okA {
+			ta, okA = toTime(a)
+		}
+		if !okB {
+			tb, okB = toTime(b)
+		}
+		if !okA || !okB {
+			return 0, false
+		}
+		return ta.Compare(tb), true
+	}
+
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			return cmpFloat(a, b), true
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0, true
+			case b:
+				return -1, true
+			}
+			return 1, true
+		}
+	case linkValue:
+		if b, ok := b.(linkValue); ok {
+			return strings.Compare(a.text, b.text), true
+		}
+	}
+	return 0, false
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// order sorts missing values last in both directions, and values that do
+// not compare by their text.
+func order(a, b any, desc bool) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	c, ok := compare(a, b)
+	if !ok {
+		c = strings.Compare(toString(a), toString(b))
+	}
+	if desc {
+		return -c
+	}
+	return c
+}
+
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case []any:
+		return len(v) > 0
+	}
+	return true
+}
+
+// toString returns the plain text of a value.
+func toString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if h, m, s := v.Clock(); h == 0 && m == 0 && s == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04")
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = toString(item)
+		}
+		return strings.Join(parts, ", ")
+	case linkValue:
+		return v.text
+	case pageRef:
+		return v.page.Title
+	case fileRef:
+		return v.page.Title
+	}
+	return fmt.Sprint(v)
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "today" || s == "now" {
+			now := time.Now()
+			if s == "today" {
+				y, m, d := now.Date()
+				return time.Date(y, m, d, 0, 0, 0, 0, time.Local), true
+			}
+			return now, true
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,550 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a query block such as
+// `TABLE title, modified FROM #project WHERE status = "active" SORT modified DESC LIMIT 20`.
+type Query struct {
+	Type      string // "table" or "list"
+	WithoutID bool
+	Columns   []Column
+	From      source
+	Where     expr
+	Sort      []Sort
+	Limit     int
+}
+
+type Column struct {
+	Name string
+	x    expr
+}
+
+type Sort struct {
+	x    expr
+	Desc bool
+}
+
+// Expressions are the columns, WHERE and SORT of a query, such as
+// `status = "active" and contains(tags, "go")`.
+type expr interface{}
+
+type literal struct{ value any }
+
+type ident struct{ name string }
+
+type member struct {
+	x    expr
+	name string
+}
+
+type linkLit struct{ target string }
+
+type call struct {
+	name string
+	args []expr
+}
+
+type unary struct {
+	op string
+	x  expr
+}
+
+type binary struct {
+	op   string
+	x, y expr
+}
+
+// Sources are the FROM of a query: tags, folders and the notes linking to a
+// note, combined with "and", "or" and "-".
+type source interface{}
+
+type tagSource struct{ tag string }
+
+type folderSource struct{ folder string }
+
+type linkSource struct{ target string }
+
+type notSource struct{ x source }
+
+type binarySource struct {
+	op   string
+	x, y source
+}
+
+// functions maps the supported functions to their number of arguments.
+var functions = map[string]int{
+	"contains": 2,
+	"date":     1,
+	"default":  2,
+	"length":   1,
+	"lower":    1,
+}
+
+var keywords = map[string]bool{
+	"TABLE": true, "LIST": true, "WITHOUT": true, "FROM": true, "WHERE": true,
+	"SORT": true, "LIMIT": true, "AS": true, "ASC": true, "DESC": true,
+	"AND": true, "OR": true,
+}
+
+var clauses = map[string]bool{"FROM": true, "WHERE": true, "SORT": true, "LIMIT": true}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokKeyword
+	tokTag
+	tokLink
+	tokOp
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	num      float64
+	pos, end int
+}
+
+var operators = []string{"!=", "<=", ">=", "=", "<", ">", "(", ")", ",", ".", "!", "+", "-", "*", "/"}
+
+func lex(src []rune) ([]token, error) {
+	var toks []token
+
+	for i := 0; i < len(src); {
+		r := src[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != r; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteRune(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			toks = append(toks, token{kind: tokString, text: b.String(), pos: i, end: j + 1})
+			i = j + 1
+
+		case r == '#':
+			j := i + 1
+			for j < len(src) && (unicode.IsLetter(src[j]) || unicode.IsDigit(src[j]) || strings.ContainsRune("_-/", src[j])) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("expected a tag after \"#\" at %d", i)
+			}
+			toks = append(toks, token{kind: tokTag, text: string(src[i+1 : j]), pos: i, end: j})
+			i = j
+
+		case r == '[' && i+1 < len(src) && src[i+1] == '[':
+			end := strings.Index(string(src[i:]), "]]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated link at %d", i)
+			}
+			j := i + len([]rune(string(src[i:])[:end]))
+			toks = append(toks, token{kind: tokLink, text: string(src[i+2 : j]), pos: i, end: j + 2})
+			i = j + 2
+
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(src) && (unicode.IsDigit(src[j]) || src[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(string(src[i:j]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", string(src[i:j]))
+			}
+			toks = append(toks, token{kind: tokNumber, num: n, text: string(src[i:j]), pos: i, end: j})
+			i = j
+
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(src[j]) || unicode.IsDigit(src[j]) || src[j] == '_') {
+				j++
+			}
+			t := token{kind: tokIdent, text: string(src[i:j]), pos: i, end: j}
+			if upper := strings.ToUpper(t.text); keywords[upper] {
+				t.kind, t.text = tokKeyword, upper
+			}
+			toks = append(toks, t)
+			i = j
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(string(src[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", string(r), i)
+			}
+			n := len([]rune(op))
+			toks = append(toks, token{kind: tokOp, text: op, pos: i, end: i + n})
+			i += n
+		}
+	}
+
+	return append(toks, token{kind: tokEOF, pos: len(src), end: len(src)}), nil
+}
+
+type parser struct {
+	src  []rune
+	toks []token
+	pos  int
+}
+
+// Parse parses the source of a query block.
+func Parse(src string) (*Query, error) {
+	rs := []rune(src)
+	toks, err := lex(rs)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: rs, toks: toks}
+
+	q := &Query{}
+	t := p.next()
+	if t.kind != tokKeyword || (t.text != "TABLE" && t.text != "LIST") {
+		return nil, fmt.Errorf("a query starts with TABLE or LIST")
+	}
+	q.Type = strings.ToLower(t.text)
+
+	if p.keyword("WITHOUT") {
+		if t := p.next(); t.kind != tokIdent || !strings.EqualFold(t.text, "id") {
+			return nil, fmt.Errorf("expected ID after WITHOUT at %d", t.pos)
+		}
+		q.WithoutID = true
+	}
+
+	if t := p.peek(); t.kind != tokEOF && !(t.kind == tokKeyword && clauses[t.text]) {
+		if err := p.columns(q); err != nil {
+			return nil, err
+		}
+	}
+	if q.WithoutID && len(q.Columns) == 0 {
+		return nil, fmt.Errorf("%s WITHOUT ID needs a column", strings.ToUpper(q.Type))
+	}
+
+	seen := make(map[string]bool)
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		if t.kind != tokKeyword || !clauses[t.text] {
+			return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+		}
+		if seen[t.text] {
+			return nil, fmt.Errorf("duplicate %s at %d", t.text, t.pos)
+		}
+		seen[t.text] = true
+
+		switch t.text {
+		case "FROM":
+			q.From, err = p.source(0)
+		case "WHERE":
+			q.Where, err = p.parse(0)
+		case "SORT":
+			err = p.sort(q)
+		case "LIMIT":
+			n := p.next()
+			if n.kind != tokNumber || n.num != math.Trunc(n.num) {
+				return nil, fmt.Errorf("expected a whole number after LIMIT at %d", n.pos)
+			}
+			q.Limit = int(n.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is the keyword kw.
+func (p *parser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tokKeyword && t.text == kw {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// op consumes the next token if it is the operator op.
+func (p *parser) op(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if t := p.next(); t.kind != tokOp || t.text != op {
+		return fmt.Errorf("expected %q at %d", op, t.pos)
+	}
+	return nil
+}
+
+// columns parses the columns of a table, or the single value of a list.
+func (p *parser) columns(q *Query) error {
+	for {
+		start := p.peek().pos
+		x, err := p.parse(0)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSpace(string(p.src[start:p.toks[p.pos-1].end]))
+
+		if p.keyword("AS") {
+			t := p.next()
+			if t.kind != tokString && t.kind != tokIdent {
+				return fmt.Errorf("expected a name after AS at %d", t.pos)
+			}
+			name = t.text
+		}
+		q.Columns = append(q.Columns, Column{Name: name, x: x})
+
+		if q.Type == "list" || !p.op(",") {
+			return nil
+		}
+	}
+}
+
+func (p *parser) sort(q *Query) error {
+	for {
+		x, err := p.parse(0)
+		if err != nil {
+			return err
+		}
+		desc := p.keyword("DESC")
+		if !desc {
+			p.keyword("ASC")
+		}
+		q.Sort = append(q.Sort, Sort{x: x, Desc: desc})
+
+		if !p.op(",") {
+			return nil
+		}
+	}
+}
+
+var binaryPrec = map[string]int{
+	"OR":  1,
+	"AND": 2,
+	"=":   3, "!=": 3, "<": 3, ">": 3, "<=": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5,
+}
+
+const unaryPrec = 6
+
+func (p *parser) parse(prec int) (expr, error) {
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind == tokOp && t.text == "." {
+			p.next()
+			name := p.next()
+			if name.kind != tokIdent && name.kind != tokKeyword {
+				return nil, fmt.Errorf("expected a name after \".\" at %d", name.pos)
+			}
+			left = member{x: left, name: string(p.src[name.pos:name.end])}
+			continue
+		}
+		if t.kind != tokOp && !(t.kind == tokKeyword && (t.text == "AND" || t.text == "OR")) {
+			return left, nil
+		}
+
+		bp := binaryPrec[t.text]
+		if bp == 0 || bp <= prec {
+			return left, nil
+		}
+		p.next()
+
+		right, err := p.parse(bp)
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: t.text, x: left, y: right}
+	}
+}
+
+func (p *parser) prefix() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return literal{t.num}, nil
+
+	case tokString:
+		return literal{t.text}, nil
+
+	case tokLink:
+		return linkLit{t.text}, nil
+
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+
+		if !p.op("(") {
+			return ident{t.text}, nil
+		}
+		name := strings.ToLower(t.text)
+		arity, ok := functions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %q at %d", t.text, t.pos)
+		}
+		args, err := p.args()
+		if err != nil {
+			return nil, err
+		}
+		if len(args) != arity {
+			return nil, fmt.Errorf("%s() takes %d arguments, got %d", name, arity, len(args))
+		}
+		// date(today) and date(now) name the current date, not a field.
+		if id, ok := args[0].(ident); ok && name == "date" && (id.name == "today" || id.name == "now") {
+			args[0] = literal{id.name}
+		}
+		return call{name: name, args: args}, nil
+
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.parse(0)
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+
+		case "!", "-":
+			x, err := p.parse(unaryPrec)
+			if err != nil {
+				return nil, err
+			}
+			return unary{op: t.text, x: x}, nil
+		}
+	}
+
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+// args parses the arguments of a call after its opening parenthesis.
+func (p *parser) args() ([]expr, error) {
+	var args []expr
+	if p.op(")") {
+		return args, nil
+	}
+
+	for {
+		x, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, x)
+
+		if p.op(")") {
+			return args, nil
+		}
+		if t := p.next(); t.kind != tokOp || t.text != "," {
+			return nil, fmt.Errorf("expected \",\" or \")\" at %d", t.pos)
+		}
+	}
+}
+
+func (p *parser) source(prec int) (source, error) {
+	left, err := p.sourcePrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind != tokKeyword || (t.text != "AND" && t.text != "OR") {
+			return left, nil
+		}
+		bp := binaryPrec[t.text]
+		if bp <= prec {
+			return left, nil
+		}
+		p.next()
+
+		right, err := p.source(bp)
+		if err != nil {
+			return nil, err
+		}
+		left = binarySource{op: t.text, x: left, y: right}
+	}
+}
+
+func (p *parser) sourcePrefix() (source, error) {
+	t := p.next()
+	switch t.kind {
+	case tokTag:
+		return tagSource{t.text}, nil
+
+	case tokString:
+		return folderSource{strings.Trim(t.text, "/")}, nil
+
+	case tokLink:
+		return linkSource{t.text}, nil
+
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.source(0)
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+
+		case "!", "-":
+			x, err := p.source(binaryPrec["AND"])
+			if err != nil {
+				return nil, err
+			}
+			return notSource{x}, nil
+		}
+	}
+
+	if t.kind == tokEOF {
+		return nil, fmt.Errorf("expected a tag, folder or link after FROM")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d in FROM", t.text, t.pos)
+}
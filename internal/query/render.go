@@ -0,0 +1,114 @@
+package query
+
+import (
+	"fmt"
+	"geode/internal/types"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var wikilinkValueReg = regexp.MustCompile(`^\[\[([^\[\]|]+)(?:\|([^\[\]]*))?\]\]$`)
+
+// Render runs the query and returns its results as HTML.
+func (q *Query) Render(env Env) string {
+	rows := q.Run(env)
+
+	var out strings.Builder
+	out.WriteString(`<div class="query">` + "\n")
+	switch {
+	case len(rows) == 0:
+		out.WriteString(`<p class="query-empty">No pages match this query.</p>` + "\n")
+	case q.Type == "list":
+		q.renderList(&out, env, rows)
+	default:
+		q.renderTable(&out, env, rows)
+	}
+	out.WriteString("</div>\n")
+	return out.String()
+}
+
+func (q *Query) renderTable(out *strings.Builder, env Env, rows []*types.MetaMarkdown) {
+	out.WriteString(`<table class="query-table">` + "\n<thead>\n<tr>")
+	if !q.WithoutID {
+		out.WriteString("<th>File</th>")
+	}
+	for _, col := range q.Columns {
+		fmt.Fprintf(out, "<th>%s</th>", html.EscapeString(col.Name))
+	}
+	out.WriteString("</tr>\n</thead>\n<tbody>\n")
+
+	for _, row := range rows {
+		out.WriteString("<tr>")
+		if !q.WithoutID {
+			fmt.Fprintf(out, "<td>%s</td>", anchor(row.Link, row.Title))
+		}
+		for _, col := range q.Columns {
+			fmt.Fprintf(out, "<td>%s</td>", env.renderValue(env.eval(col.x, row)))
+		}
+		out.WriteString("</tr>\n")
+	}
+	out.WriteString("</tbody>\n</table>\n")
+}
+
+func (q *Query) renderList(out *strings.Builder, env Env, rows []*types.MetaMarkdown) {
+	out.WriteString(`<ul class="query-list">` + "\n")
+	for _, row := range rows {
+		out.WriteString("<li>")
+		if !q.WithoutID {
+			out.WriteString(anchor(row.Link, row.Title))
+		}
+		if len(q.Columns) > 0 {
+			value := env.renderValue(env.eval(q.Columns[0].x, row))
+			if !q.WithoutID && value != "" {
+				out.WriteString(": ")
+			}
+			out.WriteString(value)
+		}
+		out.WriteString("</li>\n")
+	}
+	out.WriteString("</ul>\n")
+}
+
+// renderValue returns the HTML of a value. Links and "[[Note]]" strings link
+// to their page.
+func (env Env) renderValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if m := wikilinkValueReg.FindStringSubmatch(v); m != nil {
+			text := m[2]
+			if text == "" {
+				text = m[1]
+			}
+			if url, ok := env.resolve(m[1]); ok {
+				return anchor(url, text)
+			}
+			return html.EscapeString(text)
+		}
+		return html.EscapeString(v)
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := env.renderValue(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	case linkValue:
+		if v.url == "" {
+			return html.EscapeString(v.text)
+		}
+		return anchor(v.url, v.text)
+	case pageRef:
+		return anchor(v.page.Link, v.page.Title)
+	case fileRef:
+		return anchor(v.page.Link, v.page.Title)
+	}
+	return html.EscapeString(toString(v))
+}
+
+func anchor(href, text string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(href), html.EscapeString(text))
+}
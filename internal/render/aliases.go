@@ -0,0 +1,89 @@
+package render
+
+import (
+	"geode/internal/content"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// buildAliasIndex maps every lower-cased frontmatter alias to the note that
+// owns it. When several notes share an alias the one with the shortest
+// relative path wins, then the alphabetically first one. Aliases that are
+// shared, or that match another note's title or file name, are reported since
+// links using them may not go where the author expects.
+func buildAliasIndex(entries []content.FileEntry) map[string]content.FileEntry {
+	owners := make(map[string][]content.FileEntry)
+	basenames := make(map[string][]string) // lower-cased basename -> relative paths
+	titles := make(map[string][]string)    // lower-cased title -> relative paths
+
+	for _, entry := range entries {
+		if !entry.IsMarkdown {
+			continue
+		}
+
+		base := strings.ToLower(strings.TrimSuffix(filepath.Base(entry.RelativePath), ".md"))
+		basenames[base] = append(basenames[base], entry.RelativePath)
+		if title := strings.ToLower(strings.TrimSpace(entry.Title)); title != "" && title != base {
+			titles[title] = append(titles[title], entry.RelativePath)
+		}
+
+		seen := make(map[string]struct{}, len(entry.Aliases))
+		for _, alias := range entry.Aliases {
+			key := strings.ToLower(strings.TrimSpace(alias))
+			if key == "" {
+				continue
+			}
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			owners[key] = append(owners[key], entry)
+		}
+	}
+
+	keys := make([]string, 0, len(owners))
+	for key := range owners {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	index := make(map[string]content.FileEntry, len(owners))
+	for _, key := range keys {
+		candidates := owners[key]
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i].RelativePath, candidates[j].RelativePath
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return a < b
+		})
+
+		winner := candidates[0]
+		index[key] = winner
+
+		if len(candidates) > 1 {
+			paths := make([]string, len(candidates))
+			for i, c := range candidates {
+				paths[i] = c.RelativePath
+			}
+			log.Printf("alias %q is used by %s; links resolve to %s", key, strings.Join(paths, ", "), winner.RelativePath)
+		}
+
+		// Titles are not link targets, so links go to the alias owner. File
+		// names are matched before aliases, but only with the same case.
+		for _, path := range titles[key] {
+			if path != winner.RelativePath {
+				log.Printf("alias %q of %s collides with the title of %s; links resolve to %s", key, winner.RelativePath, path, winner.RelativePath)
+			}
+		}
+		for _, path := range basenames[key] {
+			if path != winner.RelativePath {
+				log.Printf("alias %q of %s collides with the file name of %s; links resolve to %s unless they spell the file name exactly", key, winner.RelativePath, path, winner.RelativePath)
+			}
+		}
+	}
+
+	return index
+}
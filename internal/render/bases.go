@@ -0,0 +1,173 @@
+package render
+
+import (
+	"bytes"
+	"geode/internal/bases"
+	"geode/internal/content"
+	"geode/internal/render/wikilink"
+	"geode/internal/types"
+	"log"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bases are rendered once every page is parsed, since their views list the
+// other pages. Parsing leaves a placeholder comment for each embedded base.
+var (
+	baseEmbedReg       = regexp.MustCompile(`!\[\[([^\[\]|#]+?\.base)\s*(?:#([^\[\]|]*))?(?:\|[^\[\]]*)?\]\]`)
+	basePlaceholderReg = regexp.MustCompile(`<!--geode-base:([^#>]*)#([^>]*?)-->`)
+)
+
+const basePlaceholderPrefix = "<!--geode-base:"
+
+type baseIndex struct {
+	Paths         map[string]string // relative path without .base -> path
+	ShortestPaths map[string]string // basename without .base -> path
+}
+
+func buildBaseIndex(entries []content.FileEntry) baseIndex {
+	idx := baseIndex{Paths: make(map[string]string), ShortestPaths: make(map[string]string)}
+	shortest := make(map[string]string) // basename -> shortest key
+
+	for _, entry := range entries {
+		if !entry.IsBase {
+			continue
+		}
+
+		key := filepath.ToSlash(strings.TrimSuffix(entry.RelativePath, bases.Ext))
+		idx.Paths[key] = entry.Path
+
+		base := filepath.Base(key)
+		if prev, ok := shortest[base]; !ok || len(key) < len(prev) {
+			shortest[base] = key
+			idx.ShortestPaths[base] = entry.Path
+		}
+	}
+
+	return idx
+}
+
+func (x baseIndex) resolve(target string) (string, bool) {
+	target = strings.TrimSuffix(strings.TrimSpace(target), bases.Ext)
+	target = filepath.ToSlash(strings.Trim(target, "/"))
+
+	if path, ok := x.Paths[target]; ok {
+		return path, true
+	}
+	path, ok := x.ShortestPaths[filepath.Base(target)]
+	return path, ok
+}
+
+// loadBases parses every base of entries. Bases that cannot be read are
+// left out, so their embeds render empty.
+func loadBases(entries []content.FileEntry) map[string]*bases.Base {
+	out := make(map[string]*bases.Base)
+	for _, entry := range entries {
+		if !entry.IsBase {
+			continue
+		}
+		if b := loadBase(entry.Path); b != nil {
+			out[filepath.Clean(entry.Path)] = b
+		}
+	}
+	return out
+}
+
+func loadBase(path string) *bases.Base {
+	b, err := bases.Load(path)
+	if err != nil {
+		log.Printf("base %s: %v", path, err)
+		return nil
+	}
+	for _, w := range b.Warnings() {
+		log.Printf("base %s: %s", path, w)
+	}
+	return b
+}
+
+// markBaseEmbeds replaces every "![[View.base]]" or "![[View.base#Name]]" of
+// src outside code with a placeholder and returns the paths of the embedded
+// bases.
+func (s *Site) markBaseEmbeds(src []byte) ([]byte, []string) {
+	var paths []string
+	var out bytes.Buffer
+	code := codeRanges(src)
+	pos := 0
+	for _, m := range baseEmbedReg.FindAllSubmatchIndex(src, -1) {
+		if _, ok := inCode(code, m[0]); ok {
+			continue
+		}
+		path, ok := s.baseIndex.resolve(string(src[m[2]:m[3]]))
+		if !ok {
+			continue
+		}
+		paths = append(paths, path)
+		view := ""
+		if m[4] >= 0 {
+			view = strings.TrimSpace(string(src[m[4]:m[5]]))
+		}
+		out.Write(src[pos:m[0]])
+		out.WriteString(basePlaceholderPrefix + url.QueryEscape(path) + "#" + url.QueryEscape(view) + "-->")
+		pos = m[1]
+	}
+	if pos == 0 {
+		return src, paths
+	}
+	out.Write(src[pos:])
+	return out.Bytes(), paths
+}
+
+// fillBases replaces the base placeholders of raw, the HTML of page.
+func (s *Site) fillBases(page *types.MetaMarkdown, raw string, now time.Time) string {
+	return basePlaceholderReg.ReplaceAllStringFunc(raw, func(m string) string {
+		sub := basePlaceholderReg.FindStringSubmatch(m)
+		path, err1 := url.QueryUnescape(sub[1])
+		view, err2 := url.QueryUnescape(sub[2])
+		b := s.bases[filepath.Clean(path)]
+		if err1 != nil || err2 != nil || b == nil {
+			return ""
+		}
+
+		html, warnings := b.Render(view, bases.Env{
+			Notes:   s.Pages,
+			This:    page,
+			Resolve: s.resolveLink,
+			Now:     now,
+		})
+		for _, w := range warnings {
+			s.warnOnce("base " + path + " in " + page.RelativePath + ": " + w)
+		}
+		return html
+	})
+}
+
+func (s *Site) resolveLink(target string) (string, bool) {
+	target, fragment := splitEmbedTarget(target)
+	dest, _ := s.resolver.ResolveWikilink(&wikilink.Node{
+		Target:   []byte(target),
+		Fragment: []byte(fragment),
+	})
+	return string(dest), len(dest) > 0
+}
+
+// mergePaths returns the sorted union of two sorted path lists.
+func mergePaths(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[string]struct{}, len(a)+len(b))
+	out := make([]string, 0, len(a)+len(b))
+	for _, p := range append(append([]string(nil), a...), b...) {
+		if _, ok := seen[p]; ok {
+			continue
+		}
+		seen[p] = struct{}{}
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
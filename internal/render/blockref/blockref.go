@@ -0,0 +1,110 @@
+package blockref
+
+import (
+	"regexp"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Marker matches an Obsidian block id (" ^abc-123") at the end of a line.
+var Marker = regexp.MustCompile(`(?:^|[ \t])\^([A-Za-z0-9-]+)[ \t]*$`)
+
+// AnchorID returns the HTML id used for the block id.
+func AnchorID(id string) string {
+	return "^" + id
+}
+
+// Extender gives paragraphs and list items that end with a "^id" marker an
+// HTML id, removing the marker from the rendered text. Found ids are
+// appended to IDs when it is set.
+type Extender struct {
+	IDs *[]string
+}
+
+func (e *Extender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(&Transformer{IDs: e.IDs}, 500),
+		),
+	)
+}
+
+type Transformer struct {
+	IDs *[]string
+}
+
+func (t *Transformer) Transform(node *ast.Document, reader text.Reader, _ parser.Context) {
+	source := reader.Source()
+
+	var blocks []ast.Node
+	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.Kind() {
+		case ast.KindParagraph, ast.KindTextBlock:
+			blocks = append(blocks, n)
+			return ast.WalkSkipChildren, nil
+		case ast.KindCodeBlock, ast.KindFencedCodeBlock, ast.KindHTMLBlock:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, block := range blocks {
+		id, ok := stripMarker(block, source)
+		if !ok {
+			continue
+		}
+
+		target := block
+		if parent := block.Parent(); parent != nil && parent.Kind() == ast.KindListItem {
+			target = parent
+		} else if block.ChildCount() == 0 {
+			// A marker on a line of its own names the block before it.
+			prev := block.PreviousSibling()
+			if prev == nil {
+				continue
+			}
+			parent.RemoveChild(parent, block)
+			target = prev
+		}
+		target.SetAttributeString("id", []byte(AnchorID(id)))
+
+		if t.IDs != nil {
+			*t.IDs = append(*t.IDs, id)
+		}
+	}
+}
+
+func stripMarker(block ast.Node, source []byte) (string, bool) {
+	last, ok := block.LastChild().(*ast.Text)
+	if !ok {
+		return "", false
+	}
+
+	value := last.Segment.Value(source)
+	m := Marker.FindSubmatchIndex(value)
+	if m == nil {
+		return "", false
+	}
+	id := string(value[m[2]:m[3]])
+
+	last.Segment = last.Segment.WithStop(last.Segment.Start + m[0])
+	last.Segment = last.Segment.TrimRightSpace(source)
+
+	if last.Segment.IsEmpty() {
+		prev := last.PreviousSibling()
+		block.RemoveChild(block, last)
+		if prevText, ok := prev.(*ast.Text); ok {
+			prevText.SetSoftLineBreak(false)
+			prevText.SetHardLineBreak(false)
+		}
+	}
+
+	return id, true
+}
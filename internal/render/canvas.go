@@ -0,0 +1,117 @@
+package render
+
+import (
+	"geode/internal/canvas"
+	"geode/internal/content"
+	"geode/internal/render/fields"
+	"geode/internal/types"
+	"geode/internal/utils"
+	"html"
+	"log"
+	"path"
+	"slices"
+	"strings"
+
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// parseCanvas renders a canvas as a page. Text nodes go through the markdown
+// pipeline like notes, and file nodes are rendered as a link to the file and
+// an embed of it, so links of both count as links of the canvas.
+func (s *Site) parseCanvas(entry content.FileEntry) (types.MetaMarkdown, error) {
+	c, err := canvas.Load(entry.Path)
+	if err != nil {
+		log.Printf("canvas %s: %v", entry.Path, err)
+		return types.MetaMarkdown{}, err
+	}
+
+	front := map[string]any{}
+	lang := ExtractLanguage(front, entry, s.cfg)
+	tagPrefix := s.cfg.LangPrefix(lang)
+
+	page := types.MetaMarkdown{
+		Path:           entry.Path,
+		RelativePath:   entry.RelativePath,
+		Link:           ExtractPermalink(front, entry),
+		Title:          strings.TrimSuffix(path.Base(entry.RelativePath), canvas.Ext),
+		Frontmatter:    front,
+		Lang:           lang,
+		TranslationKey: ExtractTranslationKey(front, entry, s.cfg),
+	}
+
+	var text strings.Builder
+	var tags, missing []string
+	inline := make(map[string]any)
+	content := make(map[string]string, len(c.Nodes))
+
+	for _, n := range c.Nodes {
+		var source string
+		switch n.Type {
+		case "text":
+			source = n.Text
+			text.WriteString(n.Text + "\n\n")
+		case "file":
+			source = canvasFileMarkdown(n)
+		case "link":
+			href := html.EscapeString(n.URL)
+			if gmhtml.IsDangerousURL([]byte(n.URL)) {
+				// Like links in notes, javascript: and the like get no href.
+				content[n.ID] = `<span class="external-link">` + href + `</span>`
+				continue
+			}
+			content[n.ID] = `<a class="external-link" href="` + href + `" target="_blank" rel="noopener noreferrer">` + href + `</a>`
+			continue
+		default:
+			continue
+		}
+
+		rendered, embeds := s.renderMarkdown([]byte(source), entry.Path, tagPrefix)
+		content[n.ID] = rendered.HTML
+
+		page.OutgoingLinks = append(page.OutgoingLinks, rendered.OutgoingLinks...)
+		page.BlockIDs = append(page.BlockIDs, rendered.BlockIDs...)
+		page.Tasks = append(page.Tasks, rendered.Tasks...)
+		inline = fields.Merge(inline, rendered.Fields)
+		page.HasKatex = page.HasKatex || rendered.HasKatex
+		page.HasMermaid = page.HasMermaid || rendered.HasMermaid
+		page.Embeds = mergePaths(page.Embeds, embeds)
+		tags = append(tags, rendered.Tags...)
+		for _, m := range rendered.MissingLinks {
+			if !slices.Contains(missing, m) {
+				missing = append(missing, m)
+			}
+		}
+	}
+
+	page.HTML = canvas.Render(c, content)
+	page.Fields = inline
+	slices.Sort(tags)
+	page.Tags = slices.Compact(tags)
+	page.MissingLinks = missing
+	page.WordCount = CountWords(text.String())
+	page.ReadingTime = EstimateReadingTime(page.WordCount)
+	page.Description = utils.StripMarkdown(text.String())
+	if len(page.Description) > 160 {
+		page.Description = page.Description[:160]
+	}
+
+	return page, nil
+}
+
+// canvasFileMarkdown returns the markdown of a file node: a link to the note
+// or canvas followed by an embed of the note, or just the embed of any other
+// file.
+func canvasFileMarkdown(n canvas.Node) string {
+	file := strings.TrimSpace(n.File)
+	ext := strings.ToLower(path.Ext(file))
+
+	switch ext {
+	case ".md", "":
+		target := strings.TrimSuffix(file, ".md")
+		name := path.Base(target)
+		return "[[" + target + "|" + name + "]]\n\n![[" + target + n.Subpath + "]]"
+	case canvas.Ext:
+		return "[[" + file + "|" + strings.TrimSuffix(path.Base(file), canvas.Ext) + "]]"
+	}
+	return "![[" + file + "]]"
+}
@@ -0,0 +1,443 @@
+package render
+
+import (
+	"fmt"
+	"geode/internal/bases"
+	"geode/internal/canvas"
+	"geode/internal/content"
+	"geode/internal/query"
+	"geode/internal/render/blockref"
+	"geode/internal/render/wikilink"
+	"geode/internal/utils"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	IssueUnresolvedLink = "unresolved-link"
+	IssueMissingHeading = "missing-heading"
+	IssueMissingBlock   = "missing-block"
+	IssueBrokenEmbed    = "broken-embed"
+	IssueMissingAsset   = "missing-asset"
+	IssueAmbiguousLink  = "ambiguous-link"
+	IssueInvalidBase    = "invalid-base"
+	IssueInvalidCanvas  = "invalid-canvas"
+	IssueInvalidQuery   = "invalid-query"
+	IssueURLCollision   = "url-collision"
+
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+type Issue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Target   string `json:"target"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", i.File, i.Line, i.Column, i.Kind, i.Message)
+}
+
+var (
+	checkWikilinkReg = regexp.MustCompile(`(!?)\[\[([^\[\]]+?)\]\]`)
+	checkImageReg    = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+)
+
+// Check reports every wikilink, heading fragment, embed and image of the
+// site's notes that does not resolve the way the renderer would need it to.
+func (s *Site) Check() []Issue {
+	c := newLinkChecker(s)
+
+	var issues []Issue
+	for _, entry := range s.Entries {
+		if entry.IsBase {
+			issues = append(issues, c.checkBase(entry)...)
+		}
+		if entry.IsCanvas {
+			issues = append(issues, c.checkCanvas(entry)...)
+		}
+		if !entry.IsMarkdown {
+			continue
+		}
+		issues = append(issues, c.checkFile(entry)...)
+	}
+
+	SortIssues(issues)
+	return issues
+}
+
+// SortIssues orders issues by file and position.
+func SortIssues(issues []Issue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+}
+
+type linkChecker struct {
+	site       *Site
+	anchors    map[string]map[string]struct{} // resolved page URL -> heading and block IDs
+	candidates map[string][]string            // basename -> relative paths
+	assets     map[string]struct{}            // relative asset paths
+}
+
+func newLinkChecker(s *Site) *linkChecker {
+	c := &linkChecker{
+		site:       s,
+		anchors:    make(map[string]map[string]struct{}),
+		candidates: make(map[string][]string),
+		assets:     make(map[string]struct{}),
+	}
+
+	for _, page := range s.Pages {
+		ids := make(map[string]struct{}, len(page.TableOfContents)+len(page.BlockIDs))
+		for _, item := range page.TableOfContents {
+			ids[item.ID] = struct{}{}
+		}
+		for _, id := range page.BlockIDs {
+			ids[blockref.AnchorID(id)] = struct{}{}
+		}
+		c.anchors[pageURL(page.RelativePath)] = ids
+	}
+
+	for _, entry := range s.Entries {
+		key := filepath.ToSlash(strings.TrimSuffix(entry.RelativePath, ".md"))
+		base := path.Base(key)
+		c.candidates[base] = append(c.candidates[base], key)
+
+		if entry.IsAsset {
+			c.assets[filepath.ToSlash(entry.RelativePath)] = struct{}{}
+		}
+	}
+
+	return c
+}
+
+func pageURL(relativePath string) string {
+	return "/" + utils.PageSlug(filepath.ToSlash(relativePath))
+}
+
+func (c *linkChecker) checkFile(entry content.FileEntry) []Issue {
+	src, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return []Issue{{
+			File:     entry.Path,
+			Line:     1,
+			Column:   1,
+			Kind:     IssueBrokenEmbed,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("cannot read note: %v", err),
+		}}
+	}
+
+	text := string(src)
+	bodyStart := 0
+	if strings.HasPrefix(text, "---") {
+		if idx := strings.Index(text[3:], "---"); idx >= 0 {
+			bodyStart = 3 + idx + 3
+		}
+	}
+	lineOffset := strings.Count(text[:bodyStart], "\n")
+
+	return c.checkMarkdown(entry, text[bodyStart:], lineOffset)
+}
+
+// checkMarkdown checks the links of markdown starting at line lineOffset+1
+// of entry.
+func (c *linkChecker) checkMarkdown(entry content.FileEntry, text string, lineOffset int) []Issue {
+	var issues []Issue
+	inFence := false
+	fence := ""
+
+	// A query block is parsed once its fence closes, and its issue is
+	// reported on the opening fence.
+	var queryLines []string
+	queryLine := 0
+
+	for i, line := range strings.Split(text, "\n") {
+		lineNo := lineOffset + i + 1
+		trimmed := strings.TrimSpace(line)
+
+		if inFence {
+			if strings.HasPrefix(trimmed, fence) {
+				inFence = false
+				if queryLine > 0 {
+					issues = append(issues, checkQuery(entry, strings.Join(queryLines, "\n"), queryLine)...)
+					queryLines, queryLine = nil, 0
+				}
+			} else if queryLine > 0 {
+				queryLines = append(queryLines, line)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = true
+			fence = trimmed[:3]
+			if strings.TrimSpace(trimmed[3:]) == "query" {
+				queryLine = lineNo
+			}
+			continue
+		}
+
+		// Blank out inline code so its content is not checked, keeping
+		// columns intact.
+		line = codeSpanReg.ReplaceAllStringFunc(line, func(m string) string {
+			return strings.Repeat(" ", len(m))
+		})
+
+		for _, m := range checkWikilinkReg.FindAllStringSubmatchIndex(line, -1) {
+			embed := m[3] > m[2]
+			inner := line[m[4]:m[5]]
+			col := len([]rune(line[:m[0]])) + 1
+			issues = append(issues, c.checkWikilink(entry, inner, embed, lineNo, col)...)
+		}
+
+		for _, m := range checkImageReg.FindAllStringSubmatchIndex(line, -1) {
+			dest := line[m[2]:m[3]]
+			col := len([]rune(line[:m[0]])) + 1
+			if issue, ok := c.checkImage(entry, dest, lineNo, col); ok {
+				issues = append(issues, issue)
+			}
+		}
+	}
+
+	return issues
+}
+
+func checkQuery(entry content.FileEntry, src string, line int) []Issue {
+	if _, err := query.Parse(src); err != nil {
+		return []Issue{{
+			File:     entry.Path,
+			Line:     line,
+			Column:   1,
+			Kind:     IssueInvalidQuery,
+			Severity: SeverityError,
+			Message:  err.Error(),
+		}}
+	}
+	return nil
+}
+
+func (c *linkChecker) checkWikilink(entry content.FileEntry, inner string, embed bool, line, col int) []Issue {
+	raw := inner
+	if idx := strings.IndexByte(inner, '|'); idx >= 0 {
+		inner = inner[:idx]
+	}
+
+	target := inner
+	fragment := ""
+	if idx := strings.LastIndexByte(inner, '#'); idx >= 0 {
+		target = inner[:idx]
+		fragment = inner[idx+1:]
+	}
+	target = strings.TrimSpace(target)
+	fragment = strings.TrimSpace(fragment)
+
+	issue := func(kind, severity, format string, args ...any) Issue {
+		return Issue{
+			File:     entry.Path,
+			Line:     line,
+			Column:   col,
+			Kind:     kind,
+			Severity: severity,
+			Target:   raw,
+			Message:  fmt.Sprintf(format, args...),
+		}
+	}
+
+	if target == "" {
+		return []Issue{issue(IssueUnresolvedLink, SeverityError, "link %q has no target note", raw)}
+	}
+
+	if embed && strings.HasSuffix(target, bases.Ext) {
+		basePath, ok := c.site.baseIndex.resolve(target)
+		if !ok {
+			return []Issue{issue(IssueBrokenEmbed, SeverityError, "embedded base %q does not exist", target)}
+		}
+		if b := c.site.bases[filepath.Clean(basePath)]; b != nil && fragment != "" && !b.HasView(fragment) {
+			return []Issue{issue(IssueBrokenEmbed, SeverityError, "view %q not found in %q", fragment, target)}
+		}
+		return nil
+	}
+
+	var issues []Issue
+
+	normalized := filepath.ToSlash(strings.Trim(strings.TrimSuffix(target, ".md"), "/"))
+	if _, exact := c.site.resolver.Pages[normalized]; !exact {
+		if paths := c.candidates[path.Base(normalized)]; len(paths) > 1 {
+			sorted := append([]string(nil), paths...)
+			sort.Strings(sorted)
+			issues = append(issues, issue(IssueAmbiguousLink, SeverityWarning,
+				"%q matches %d notes (%s); the shortest path is used",
+				target, len(sorted), strings.Join(sorted, ", ")))
+		}
+	}
+
+	isNote := !isAssetTarget(target)
+
+	if embed && isNote {
+		notePath, ok := c.site.embedIndex.resolve(target)
+		if !ok {
+			return append(issues, issue(IssueBrokenEmbed, SeverityError, "embedded note %q does not exist", target))
+		}
+		if fragment != "" {
+			body, err := os.ReadFile(notePath)
+			if err != nil {
+				return append(issues, issue(IssueBrokenEmbed, SeverityError, "cannot read embedded note %q: %v", target, err))
+			}
+			_, body = extractFrontmatter(body)
+			if id, isBlock := strings.CutPrefix(fragment, "^"); isBlock {
+				if _, ok := extractMarkdownBlock(body, strings.TrimSpace(id)); !ok {
+					issues = append(issues, issue(IssueBrokenEmbed, SeverityError, "embedded block %q not found in %q", fragment, target))
+				}
+			} else if _, ok := extractMarkdownSection(body, transformHeadingID(fragment)); !ok {
+				issues = append(issues, issue(IssueBrokenEmbed, SeverityError, "embedded section %q not found in %q", fragment, target))
+			}
+		}
+		return issues
+	}
+
+	dest, _ := c.site.resolver.ResolveWikilink(&wikilink.Node{Target: []byte(target)})
+	if len(dest) == 0 {
+		if embed || !isNote {
+			return append(issues, issue(IssueMissingAsset, SeverityError, "asset %q does not exist", target))
+		}
+		return append(issues, issue(IssueUnresolvedLink, SeverityError, "note %q does not exist", target))
+	}
+
+	if fragment != "" && isNote {
+		ids, ok := c.anchors[string(dest)]
+		if !ok {
+			return issues
+		}
+		if _, found := ids[wikilink.FragmentID(fragment)]; !found {
+			if strings.HasPrefix(fragment, "^") {
+				issues = append(issues, issue(IssueMissingBlock, SeverityError, "block %q not found in %q", fragment, target))
+			} else {
+				issues = append(issues, issue(IssueMissingHeading, SeverityError, "heading %q not found in %q", fragment, target))
+			}
+		}
+	}
+
+	return issues
+}
+
+// checkBase reports bases that do not parse and the expressions of a base
+// that are skipped when it is rendered.
+func (c *linkChecker) checkBase(entry content.FileEntry) []Issue {
+	issue := func(severity, msg string) Issue {
+		return Issue{
+			File:     entry.Path,
+			Line:     1,
+			Column:   1,
+			Kind:     IssueInvalidBase,
+			Severity: severity,
+			Message:  msg,
+		}
+	}
+
+	b, err := bases.Load(entry.Path)
+	if err != nil {
+		return []Issue{issue(SeverityError, err.Error())}
+	}
+
+	var issues []Issue
+	for _, w := range b.Warnings() {
+		issues = append(issues, issue(SeverityWarning, w))
+	}
+	return issues
+}
+
+// checkCanvas checks the links of the text nodes of a canvas and the files
+// of its file nodes. Issues are reported on the first line of the canvas, with
+// the id of the node in the message.
+func (c *linkChecker) checkCanvas(entry content.FileEntry) []Issue {
+	cv, err := canvas.Load(entry.Path)
+	if err != nil {
+		return []Issue{{
+			File:     entry.Path,
+			Line:     1,
+			Column:   1,
+			Kind:     IssueInvalidCanvas,
+			Severity: SeverityError,
+			Message:  err.Error(),
+		}}
+	}
+
+	var issues []Issue
+	for _, n := range cv.Nodes {
+		var found []Issue
+		switch n.Type {
+		case "text":
+			found = c.checkMarkdown(entry, n.Text, 0)
+		case "file":
+			target := strings.TrimSuffix(n.File, ".md") + n.Subpath
+			found = c.checkWikilink(entry, target, !strings.HasSuffix(n.File, canvas.Ext), 1, 1)
+		}
+		for _, issue := range found {
+			issue.Line, issue.Column = 1, 1
+			issue.Message = fmt.Sprintf("%s node %s: %s", n.Type, n.ID, issue.Message)
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func (c *linkChecker) checkImage(entry content.FileEntry, dest string, line, col int) (Issue, bool) {
+	if strings.HasPrefix(dest, "//") || strings.HasPrefix(dest, "#") {
+		return Issue{}, false
+	}
+	if u, err := url.Parse(dest); err != nil || u.Scheme != "" {
+		return Issue{}, false
+	}
+
+	clean := dest
+	if idx := strings.IndexAny(clean, "?#"); idx >= 0 {
+		clean = clean[:idx]
+	}
+	if unescaped, err := url.PathUnescape(clean); err == nil {
+		clean = unescaped
+	}
+
+	var rel string
+	if strings.HasPrefix(clean, "/") {
+		rel = path.Clean(strings.TrimPrefix(clean, "/"))
+	} else {
+		dir := path.Dir(filepath.ToSlash(entry.RelativePath))
+		rel = path.Clean(path.Join(dir, clean))
+	}
+
+	if _, ok := c.assets[rel]; ok {
+		return Issue{}, false
+	}
+
+	return Issue{
+		File:     entry.Path,
+		Line:     line,
+		Column:   col,
+		Kind:     IssueMissingAsset,
+		Severity: SeverityError,
+		Target:   dest,
+		Message:  fmt.Sprintf("image %q does not exist", dest),
+	}, true
+}
+
+func isAssetTarget(target string) bool {
+	ext := strings.ToLower(path.Ext(target))
+	return ext != "" && ext != ".md" && content.IsAssetFile(ext)
+}
@@ -0,0 +1,58 @@
+package render
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+var codeSpanReg = regexp.MustCompile("`[^`]*`")
+
+// codeRanges returns the byte ranges of the fenced code blocks and inline
+// code spans of src, where embeds are left as written.
+func codeRanges(src []byte) [][2]int {
+	var ranges [][2]int
+	fence := ""
+	fenceStart := 0
+
+	for offset := 0; offset < len(src); {
+		lineEnd := bytes.IndexByte(src[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(src)
+		} else {
+			lineEnd += offset + 1
+		}
+		line := src[offset:lineEnd]
+		trimmed := string(bytes.TrimSpace(line))
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				ranges = append(ranges, [2]int{fenceStart, lineEnd})
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			fenceStart = offset
+		default:
+			for _, m := range codeSpanReg.FindAllIndex(line, -1) {
+				ranges = append(ranges, [2]int{offset + m[0], offset + m[1]})
+			}
+		}
+		offset = lineEnd
+	}
+	if fence != "" {
+		ranges = append(ranges, [2]int{fenceStart, len(src)})
+	}
+	return ranges
+}
+
+// inCode returns the end of the code range containing pos.
+func inCode(ranges [][2]int, pos int) (int, bool) {
+	for _, r := range ranges {
+		if pos >= r[0] && pos < r[1] {
+			return r[1], true
+		}
+	}
+	return 0, false
+}
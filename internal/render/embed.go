@@ -0,0 +1,186 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"geode/internal/cache"
+	"geode/internal/content"
+	"geode/internal/render/wikilink"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// embedFrame is a note embedded in framed mode. Its markdown is rendered on
+// its own, so its headings, links and tags are not attributed to the page
+// that embeds it.
+type embedFrame struct {
+	Title  string
+	Link   string
+	Source []byte // may contain placeholders of nested embeds
+}
+
+const maxEmbedDepth = 20
+
+var embedPlaceholderReg = regexp.MustCompile(`<!--geode-embed:(\d+)-->`)
+
+func embedPlaceholder(i int) string {
+	return "<!--geode-embed:" + strconv.Itoa(i) + "-->"
+}
+
+// frameMarkdownEmbeds replaces every note embed of src with a placeholder
+// comment and returns the embedded notes as frames, together with the sorted
+// paths of all embedded notes.
+func (s *Site) frameMarkdownEmbeds(src []byte, rootPath string) ([]byte, []embedFrame, []string) {
+	f := &embedFramer{
+		site:     s,
+		includes: map[string]struct{}{rootPath: {}},
+		embedded: make(map[string]struct{}),
+	}
+	out := f.expand(src, 0)
+
+	paths := make([]string, 0, len(f.embedded))
+	for path := range f.embedded {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return out, f.frames, paths
+}
+
+type embedFramer struct {
+	site     *Site
+	includes map[string]struct{} // notes on the current embed chain
+	embedded map[string]struct{}
+	frames   []embedFrame
+}
+
+func (f *embedFramer) expand(src []byte, depth int) []byte {
+	var out bytes.Buffer
+	out.Grow(len(src))
+
+	code := codeRanges(src)
+	pos := 0
+	for pos < len(src) {
+		start := bytes.Index(src[pos:], []byte("![["))
+		if start < 0 {
+			break
+		}
+		start += pos
+		if codeEnd, ok := inCode(code, start); ok {
+			_, _ = out.Write(src[pos:codeEnd])
+			pos = codeEnd
+			continue
+		}
+		end := bytes.Index(src[start+3:], []byte("]]"))
+		if end < 0 {
+			break
+		}
+		end += start + 3
+
+		_, _ = out.Write(src[pos:start])
+		literal := src[start : end+2]
+		inner := strings.TrimSpace(string(src[start+3 : end]))
+		pos = end + 2
+
+		path, front, body, ok := f.site.embedIndex.load(inner)
+		if !ok {
+			_, _ = out.Write(literal)
+			continue
+		}
+		if _, seen := f.includes[path]; seen || depth >= maxEmbedDepth {
+			continue
+		}
+
+		f.includes[path] = struct{}{}
+		f.embedded[path] = struct{}{}
+
+		// Reserve the index first so nested embeds always come after their
+		// parent.
+		idx := len(f.frames)
+		f.frames = append(f.frames, embedFrame{})
+		nested := f.expand(body, depth+1)
+		delete(f.includes, path)
+
+		target, fragment := splitEmbedTarget(inner)
+		link, _ := f.site.resolver.ResolveWikilink(&wikilink.Node{
+			Target:   []byte(target),
+			Fragment: []byte(fragment),
+		})
+
+		title := ExtractTitle(front, content.FileEntry{RelativePath: path})
+		if fragment != "" && !strings.HasPrefix(fragment, "^") {
+			title += " › " + fragment
+		}
+
+		f.frames[idx] = embedFrame{Title: title, Link: string(link), Source: nested}
+
+		_, _ = out.WriteString(embedPlaceholder(idx))
+	}
+
+	_, _ = out.Write(src[pos:])
+	return out.Bytes()
+}
+
+// framesKey returns the part of the cache key covering the embedded notes.
+func framesKey(frames []embedFrame) []byte {
+	var b bytes.Buffer
+	for _, f := range frames {
+		fmt.Fprintf(&b, "%d:%s\x00%d:%s\x00%d:", len(f.Title), f.Title, len(f.Link), f.Link, len(f.Source))
+		_, _ = b.Write(f.Source)
+	}
+	return b.Bytes()
+}
+
+// renderWithFrames renders source and replaces the placeholders left by
+// frameMarkdownEmbeds with the rendered frames. Only the host page's links,
+// headings, tags and block ids end up in the returned entry.
+func renderWithFrames(source []byte, frames []embedFrame, resolver wikilink.Resolver, tagPrefix string) cache.Entry {
+	rendered := renderToHTML(source, resolver, tagPrefix)
+	if len(frames) == 0 {
+		return rendered
+	}
+
+	framed := make([]string, len(frames))
+	splice := func(s string) string {
+		return embedPlaceholderReg.ReplaceAllStringFunc(s, func(m string) string {
+			i, err := strconv.Atoi(embedPlaceholderReg.FindStringSubmatch(m)[1])
+			if err != nil || i >= len(framed) {
+				return ""
+			}
+			return framed[i]
+		})
+	}
+
+	for i := len(frames) - 1; i >= 0; i-- {
+		entry := renderToHTML(frames[i].Source, resolver, tagPrefix)
+		rendered.HasKatex = rendered.HasKatex || entry.HasKatex
+		rendered.HasMermaid = rendered.HasMermaid || entry.HasMermaid
+		framed[i] = frameHTML(frames[i], splice(entry.HTML))
+	}
+
+	rendered.HTML = splice(rendered.HTML)
+	return rendered
+}
+
+func frameHTML(f embedFrame, body string) string {
+	var b strings.Builder
+	b.WriteString(`<div class="markdown-embed">` + "\n")
+	b.WriteString(`<div class="markdown-embed-title">`)
+	if f.Link != "" {
+		b.WriteString(`<a href="` + html.EscapeString(string(util.URLEscape([]byte(f.Link), true))) + `">`)
+		b.WriteString(html.EscapeString(f.Title))
+		b.WriteString(`</a>`)
+	} else {
+		b.WriteString(html.EscapeString(f.Title))
+	}
+	b.WriteString("</div>\n")
+	b.WriteString(`<div class="markdown-embed-content">` + "\n")
+	b.WriteString(body)
+	b.WriteString("</div>\n</div>\n")
+	return b.String()
+}
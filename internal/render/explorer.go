@@ -43,6 +43,7 @@ func insertIntoTree(node *types.FileTree, segments []string, page types.MetaMark
 		child.Path = page.RelativePath
 		child.Title = page.Title
 		child.Link = page.Link
+		child.Lang = page.Lang
 		return
 	}
 
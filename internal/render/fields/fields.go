@@ -0,0 +1,323 @@
+package fields
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Inline fields are Dataview style key/value pairs in the body of a note,
+// either starting a line, "status:: active", or in brackets anywhere in the
+// text, "[due:: 2025-01-01]".
+var (
+	lineKeyReg    = regexp.MustCompile(`^([\p{L}\p{N}_][\p{L}\p{N}_ -]*?)::(?:[ \t]|$)`)
+	bracketKeyReg = regexp.MustCompile(`^\[[ \t]*([\p{L}\p{N}_][\p{L}\p{N}_ -]*?)[ \t]*::[ \t]*`)
+)
+
+type Extender struct{}
+
+func (e *Extender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(
+			// Before wikilinks and links, which also start with "[".
+			util.Prioritized(&Parser{}, 198),
+		),
+		parser.WithASTTransformers(
+			util.Prioritized(&Transformer{}, 600),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&Renderer{}, 500),
+		),
+	)
+}
+
+var Kind = ast.NewNodeKind("InlineField")
+
+// Node is an inline field. Its children are the rendered value, Value is
+// the value as written.
+type Node struct {
+	ast.BaseInline
+	Key   string
+	Value string
+}
+
+func (n *Node) Kind() ast.NodeKind {
+	return Kind
+}
+
+func (n *Node) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Key":   n.Key,
+		"Value": n.Value,
+	}, nil)
+}
+
+// opener is the "[key::" of a bracketed field until its closing "]" is
+// found. The value in between is parsed like any other text, so it can hold
+// links and emphasis.
+type opener struct {
+	ast.BaseInline
+	Segment text.Segment
+	key     string
+	bottom  ast.Node
+	prev    *opener
+}
+
+var kindOpener = ast.NewNodeKind("InlineFieldOpener")
+
+func (o *opener) Kind() ast.NodeKind {
+	return kindOpener
+}
+
+func (o *opener) Dump(source []byte, level int) {
+	ast.DumpHelper(o, source, level, map[string]string{"Key": o.key}, nil)
+}
+
+var openerKey = parser.NewContextKey()
+
+type Parser struct{}
+
+func (p *Parser) Trigger() []byte {
+	return []byte{'[', ']'}
+}
+
+func (p *Parser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	if line[0] == ']' {
+		return p.close(parent, block, pc, segment)
+	}
+
+	m := bracketKeyReg.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+
+	prev, _ := pc.Get(openerKey).(*opener)
+	o := &opener{
+		Segment: text.NewSegment(segment.Start, segment.Start+len(m[0])),
+		key:     strings.TrimSpace(string(m[1])),
+		bottom:  pc.LastDelimiter(),
+		prev:    prev,
+	}
+	pc.Set(openerKey, o)
+	block.Advance(len(m[0]))
+	return o
+}
+
+func (p *Parser) close(parent ast.Node, block text.Reader, pc parser.Context, segment text.Segment) ast.Node {
+	// A field ends on the line it starts on; fields left open on an earlier
+	// line are text.
+	o, _ := pc.Get(openerKey).(*opener)
+	for o != nil && bytes.IndexByte(block.Source()[o.Segment.Stop:segment.Start], '\n') >= 0 {
+		o.revert()
+		o = o.prev
+		pc.Set(openerKey, o)
+	}
+	if o == nil || o.Parent() != parent {
+		return nil
+	}
+	// A "[" opened after the field closes first, as a link.
+	for c := o.NextSibling(); c != nil; c = c.NextSibling() {
+		if c.Kind().String() == "LinkLabelState" {
+			return nil
+		}
+	}
+
+	pc.Set(openerKey, o.prev)
+	block.Advance(1)
+	parser.ProcessDelimiters(o.bottom, pc)
+
+	n := &Node{
+		Key:   o.key,
+		Value: strings.TrimSpace(string(block.Source()[o.Segment.Stop:segment.Start])),
+	}
+	for c := o.NextSibling(); c != nil; {
+		next := c.NextSibling()
+		parent.RemoveChild(parent, c)
+		n.AppendChild(n, c)
+		c = next
+	}
+	parent.RemoveChild(parent, o)
+	return n
+}
+
+// CloseBlock turns the fields that were never closed back into text.
+func (p *Parser) CloseBlock(parent ast.Node, block text.Reader, pc parser.Context) {
+	for o, _ := pc.Get(openerKey).(*opener); o != nil; o = o.prev {
+		o.revert()
+	}
+	pc.Set(openerKey, nil)
+}
+
+// revert turns o back into the text it was parsed from.
+func (o *opener) revert() {
+	if o.Parent() != nil {
+		o.Parent().ReplaceChild(o.Parent(), o, ast.NewTextSegment(o.Segment))
+	}
+}
+
+// Transformer wraps the lines of paragraphs and list items starting with
+// "key::" in fields. The value runs to the end of the line.
+type Transformer struct{}
+
+func (t *Transformer) Transform(node *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var starts []*ast.Text
+	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || (n.Kind() != ast.KindParagraph && n.Kind() != ast.KindTextBlock) {
+			return ast.WalkContinue, nil
+		}
+
+		lineStart := true
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			t, ok := c.(*ast.Text)
+			if !ok {
+				lineStart = false
+				continue
+			}
+			if lineStart && lineKeyReg.Match(t.Segment.Value(source)) {
+				starts = append(starts, t)
+			}
+			lineStart = t.SoftLineBreak() || t.HardLineBreak()
+		}
+		return ast.WalkSkipChildren, nil
+	})
+
+	for _, start := range starts {
+		wrapLine(start, source)
+	}
+}
+
+func wrapLine(start *ast.Text, source []byte) {
+	m := lineKeyReg.FindSubmatch(start.Segment.Value(source))
+	value := start.Segment.WithStart(start.Segment.Start + len(m[0]))
+	value = value.TrimLeftSpace(source)
+
+	end := bytes.IndexByte(source[value.Start:], '\n')
+	if end < 0 {
+		end = len(source) - value.Start
+	}
+	n := &Node{
+		Key:   strings.TrimSpace(string(m[1])),
+		Value: strings.TrimSpace(string(source[value.Start : value.Start+end])),
+	}
+
+	parent := start.Parent()
+	parent.InsertBefore(parent, start, n)
+	for c := ast.Node(start); c != nil; {
+		next := c.NextSibling()
+		parent.RemoveChild(parent, c)
+		n.AppendChild(n, c)
+		if t, ok := c.(*ast.Text); ok && (t.SoftLineBreak() || t.HardLineBreak()) {
+			break
+		}
+		c = next
+	}
+	start.Segment = value
+
+	// The text of a line can be split after "key::", so the space before the
+	// value may start the next node.
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		t, ok := c.(*ast.Text)
+		if !ok {
+			break
+		}
+		t.Segment = t.Segment.TrimLeftSpace(source)
+		if t.Segment.Len() > 0 || t.SoftLineBreak() || t.HardLineBreak() {
+			break
+		}
+	}
+}
+
+type Renderer struct{}
+
+func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(Kind, r.Render)
+}
+
+func (r *Renderer) Render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.WriteString(`<span class="inline-field"><span class="inline-field-key">`)
+		w.Write(util.EscapeHTML([]byte(node.(*Node).Key)))
+		w.WriteString(`</span><span class="inline-field-value">`)
+	} else {
+		w.WriteString("</span></span>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// Collect returns the fields of doc by key. A key written more than once
+// holds the list of its values.
+func Collect(doc ast.Node) map[string]any {
+	var fields map[string]any
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if f, ok := n.(*Node); ok && entering {
+			if fields == nil {
+				fields = make(map[string]any)
+			}
+			add(fields, f.Key, parseValue(f.Value))
+		}
+		return ast.WalkContinue, nil
+	})
+	return fields
+}
+
+// Merge returns the frontmatter of a note together with its inline fields.
+// A key set in both holds the list of all their values.
+func Merge(front, inline map[string]any) map[string]any {
+	out := make(map[string]any, len(front)+len(inline))
+	for k, v := range front {
+		out[k] = v
+	}
+	for k, v := range inline {
+		if list, ok := v.([]any); ok {
+			for _, item := range list {
+				add(out, k, item)
+			}
+		} else {
+			add(out, k, v)
+		}
+	}
+	return out
+}
+
+func add(fields map[string]any, key string, v any) {
+	prev, ok := fields[key]
+	if !ok {
+		fields[key] = v
+		return
+	}
+	if list, ok := prev.([]any); ok {
+		fields[key] = append(append([]any(nil), list...), v)
+		return
+	}
+	fields[key] = []any{prev, v}
+}
+
+// parseValue reads booleans and numbers; anything else stays text, like
+// dates and "[[links]]" in the frontmatter.
+func parseValue(s string) any {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if s != "" && strings.ContainsRune("0123456789-.", rune(s[0])) {
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"geode/internal/config"
+	"geode/internal/content"
+)
+
+// ExtractLanguage returns the language of a note: the "lang" frontmatter,
+// else the language folder the note lives in, such as content/id/, else the
+// default language. Only the languages of i18n.languages are recognised.
+func ExtractLanguage(front map[string]any, entry content.FileEntry, cfg *config.Config) string {
+	if s, ok := front["lang"].(string); ok {
+		if lang, ok := cfg.Language(strings.TrimSpace(s)); ok {
+			return lang.Code
+		}
+	}
+
+	if folder := languageFolder(entry.RelativePath, cfg); folder != "" {
+		return folder
+	}
+
+	return cfg.I18n.Default
+}
+
+// ExtractTranslationKey returns the key shared by the translations of a
+// note: the "translationKey" frontmatter, else the path of the note without
+// its language folder, so that content/en/About.md and content/id/About.md
+// are translations of each other.
+func ExtractTranslationKey(front map[string]any, entry content.FileEntry, cfg *config.Config) string {
+	if s, ok := front["translationKey"].(string); ok && strings.TrimSpace(s) != "" {
+		return strings.TrimSpace(s)
+	}
+
+	rel := filepath.ToSlash(entry.RelativePath)
+	if folder := languageFolder(rel, cfg); folder != "" {
+		rel = strings.TrimPrefix(rel, folder+"/")
+	}
+	return strings.TrimSuffix(path.Clean(rel), ".md")
+}
+
+// languageFolder returns the top folder of rel when it is named after a site
+// language.
+func languageFolder(rel string, cfg *config.Config) string {
+	if !cfg.Multilingual() {
+		return ""
+	}
+
+	top, _, found := strings.Cut(filepath.ToSlash(rel), "/")
+	if !found {
+		return ""
+	}
+	if _, ok := cfg.Language(top); ok {
+		return top
+	}
+	return ""
+}
@@ -2,18 +2,24 @@ package render
 
 import (
 	"bytes"
+	"geode/internal/cache"
+	"geode/internal/config"
 	"geode/internal/content"
 	"geode/internal/render/anchor"
+	"geode/internal/render/blockref"
 	"geode/internal/render/callout"
 	"geode/internal/render/externallink"
+	"geode/internal/render/fields"
 	"geode/internal/render/highlight"
 	"geode/internal/render/mark"
 	"geode/internal/render/media"
 	"geode/internal/render/mermaid"
+	queryblock "geode/internal/render/query"
 	hashtag "geode/internal/render/tags"
 	"geode/internal/render/wikilink"
 	"geode/internal/types"
 	"geode/internal/utils"
+	"log"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -30,101 +36,152 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-func ParsingMarkdown(entries []content.FileEntry) []types.MetaMarkdown {
-	pages := make([]types.MetaMarkdown, 0, len(entries))
-	urlToIndex := make(map[string]int, len(entries))
+func ParsingMarkdown(entries []content.FileEntry, cfg *config.Config) []types.MetaMarkdown {
+	return NewSite(entries, cfg).Pages
+}
+
+func (s *Site) parsePage(entry content.FileEntry) (types.MetaMarkdown, error) {
+	if entry.IsCanvas {
+		return s.parseCanvas(entry)
+	}
+
+	contentBytes, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return types.MetaMarkdown{}, err
+	}
+
+	frontmatter, body := extractFrontmatter(contentBytes)
+	title := ExtractTitle(frontmatter, entry)
+	link := ExtractPermalink(frontmatter, entry)
+	lang := ExtractLanguage(frontmatter, entry, s.cfg)
+	tagPrefix := s.cfg.LangPrefix(lang)
+
+	wordCount := CountWords(string(body))
+	readingTime := EstimateReadingTime(wordCount)
+
+	rendered, embeds := s.renderMarkdown(body, entry.Path, tagPrefix)
+
+	tags := mergeTags(parseFrontmatterTags(frontmatter), rendered.Tags)
+	description := ExtractDescription(frontmatter, entry)
+	if description == "" {
+		description = utils.StripMarkdown(string(body))
+		if len(description) > 160 {
+			description = description[:160]
+		}
+	}
+
+	return types.MetaMarkdown{
+		Path:            entry.Path,
+		RelativePath:    entry.RelativePath,
+		Link:            link,
+		Title:           title,
+		Frontmatter:     frontmatter,
+		Fields:          fields.Merge(frontmatter, rendered.Fields),
+		Tags:            tags,
+		ReadingTime:     readingTime,
+		WordCount:       wordCount,
+		HTML:            rendered.HTML,
+		OutgoingLinks:   rendered.OutgoingLinks,
+		TableOfContents: rendered.TableOfContents,
+		HasKatex:        rendered.HasKatex,
+		HasMermaid:      rendered.HasMermaid,
+		MissingLinks:    rendered.MissingLinks,
+		BlockIDs:        rendered.BlockIDs,
+		Tasks:           rendered.Tasks,
+		Description:     description,
+		Embeds:          embeds,
+		Lang:            lang,
+		TranslationKey:  ExtractTranslationKey(frontmatter, entry, s.cfg),
+	}, nil
+}
+
+// renderMarkdown renders markdown read from path, a note or a canvas, with
+// its embeds and through the build cache. It returns the rendered entry and
+// the sorted paths of the embedded notes and bases.
+func (s *Site) renderMarkdown(body []byte, path, tagPrefix string) (cache.Entry, []string) {
+	var (
+		source []byte
+		frames []embedFrame
+		embeds []string
+	)
+	if s.embedMode == config.EmbedsInline {
+		source, embeds = expandMarkdownEmbeds(body, s.embedIndex, path)
+	} else {
+		source, frames, embeds = s.frameMarkdownEmbeds(body, path)
+	}
+
+	source, baseEmbeds := s.markBaseEmbeds(source)
+	for i := range frames {
+		var more []string
+		frames[i].Source, more = s.markBaseEmbeds(frames[i].Source)
+		baseEmbeds = append(baseEmbeds, more...)
+	}
+	embeds = mergePaths(embeds, baseEmbeds)
+
+	key := cache.Key([]byte(s.cacheKey), []byte(tagPrefix), source, framesKey(frames))
+	rendered, ok := s.cache.Get(key)
+	if !ok {
+		rendered = renderWithFrames(source, frames, s.resolver, tagPrefix)
+		if err := s.cache.Put(key, rendered); err != nil {
+			log.Printf("cache write failed for %s: %v", path, err)
+		}
+	}
+
+	return rendered, embeds
+}
+
+// ResolveBacklinks recomputes the backlinks of every page from the outgoing
+// links of all pages, in page order.
+func ResolveBacklinks(pages []types.MetaMarkdown) {
+	urlToIndex := make(map[string]int, len(pages))
 	pendingBacklinks := make(map[string][]types.Link)
 	seenBacklinks := make(map[string]map[string]bool) // targetURL -> sourceURL -> seen
 
-	resolver := buildResolver(entries)
-	embedIndex := buildEmbedIndex(entries)
+	for i := range pages {
+		pages[i].Backlinks = nil
+	}
 
-	for _, entry := range entries {
-		if entry.IsAsset {
-			continue
-		} else if entry.IsMarkdown {
+	for pageIndex := range pages {
+		link := pages[pageIndex].Link
+		if link != "" {
+			urlToIndex[link] = pageIndex
+			if pending, ok := pendingBacklinks[link]; ok {
+				pages[pageIndex].Backlinks = append(pages[pageIndex].Backlinks, pending...)
+				delete(pendingBacklinks, link)
+			}
+		}
 
-			contentBytes, err := os.ReadFile(entry.Path)
-			if err != nil {
+		sourceLink := types.Link{Title: pages[pageIndex].Title, URL: link}
+		for _, out := range pages[pageIndex].OutgoingLinks {
+			targetURL := out.URL
+			if targetURL == "" || targetURL == link {
 				continue
 			}
 
-			frontmatter, body := extractFrontmatter(contentBytes)
-			title := ExtractTitle(frontmatter, entry)
-			link := ExtractPermalink(frontmatter, entry)
-
-			wordCount := CountWords(string(body))
-			readingTime := EstimateReadingTime(wordCount)
-
-			htmlOut, outgoingLinks, toc, contentTags, hasKatex, hasMermaid := renderToHTML(body, resolver, embedIndex, entry.Path)
-			tags := mergeTags(parseFrontmatterTags(frontmatter), contentTags)
-			description := ExtractDescription(frontmatter, entry)
-			if description == "" {
-				description = utils.StripMarkdown(string(body))
-				if len(description) > 160 {
-					description = description[:160]
-				}
-			}
-			page := types.MetaMarkdown{
-				Path:            entry.Path,
-				RelativePath:    entry.RelativePath,
-				Link:            link,
-				Title:           title,
-				Frontmatter:     frontmatter,
-				Tags:            tags,
-				ReadingTime:     readingTime,
-				WordCount:       wordCount,
-				HTML:            htmlOut,
-				OutgoingLinks:   outgoingLinks,
-				TableOfContents: toc,
-				HasKatex:        hasKatex,
-				HasMermaid:      hasMermaid,
-				Description:     description,
+			if _, ok := seenBacklinks[targetURL]; !ok {
+				seenBacklinks[targetURL] = make(map[string]bool)
 			}
-
-			pages = append(pages, page)
-			pageIndex := len(pages) - 1
-			if link != "" {
-				urlToIndex[link] = pageIndex
-				if pending, ok := pendingBacklinks[link]; ok {
-					pages[pageIndex].Backlinks = append(pages[pageIndex].Backlinks, pending...)
-					delete(pendingBacklinks, link)
-				}
+			if seenBacklinks[targetURL][sourceLink.URL] {
+				continue
 			}
+			seenBacklinks[targetURL][sourceLink.URL] = true
 
-			sourceLink := types.Link{Title: title, URL: link}
-			for _, out := range outgoingLinks {
-				targetURL := out.URL
-				if targetURL == "" || targetURL == link {
-					continue
-				}
-
-				if _, ok := seenBacklinks[targetURL]; !ok {
-					seenBacklinks[targetURL] = make(map[string]bool)
-				}
-				if seenBacklinks[targetURL][sourceLink.URL] {
-					continue
-				}
-				seenBacklinks[targetURL][sourceLink.URL] = true
-
-				if idx, ok := urlToIndex[targetURL]; ok {
-					pages[idx].Backlinks = append(pages[idx].Backlinks, sourceLink)
-				} else {
-					pendingBacklinks[targetURL] = append(pendingBacklinks[targetURL], sourceLink)
-				}
+			if idx, ok := urlToIndex[targetURL]; ok {
+				pages[idx].Backlinks = append(pages[idx].Backlinks, sourceLink)
+			} else {
+				pendingBacklinks[targetURL] = append(pendingBacklinks[targetURL], sourceLink)
 			}
 		}
 	}
-
-	return pages
 }
 
 type embedResolver struct {
 	Pages         map[string]string
 	ShortestPaths map[string]string
+	Aliases       map[string]string
 }
 
-func buildEmbedIndex(entries []content.FileEntry) embedResolver {
+func buildEmbedIndex(entries []content.FileEntry, aliases map[string]content.FileEntry) embedResolver {
 	pages := make(map[string]string)
 	shortestPaths := make(map[string]string)
 	baseNamePaths := make(map[string][]string)
@@ -152,7 +209,12 @@ func buildEmbedIndex(entries []content.FileEntry) embedResolver {
 		shortestPaths[base] = pages[shortestKey]
 	}
 
-	return embedResolver{Pages: pages, ShortestPaths: shortestPaths}
+	aliasPaths := make(map[string]string, len(aliases))
+	for alias, entry := range aliases {
+		aliasPaths[alias] = entry.Path
+	}
+
+	return embedResolver{Pages: pages, ShortestPaths: shortestPaths, Aliases: aliasPaths}
 }
 
 func (r embedResolver) resolve(target string) (string, bool) {
@@ -170,17 +232,70 @@ func (r embedResolver) resolve(target string) (string, bool) {
 		return dest, true
 	}
 
+	if dest, ok := r.Aliases[strings.ToLower(target)]; ok {
+		return dest, true
+	}
+
 	return "", false
 }
 
-func expandMarkdownEmbeds(src []byte, r embedResolver, rootPath string) []byte {
+// splitEmbedTarget splits the inside of "![[...]]" into the target note and
+// its heading or "^block" fragment, dropping any "|label".
+func splitEmbedTarget(inner string) (target, fragment string) {
+	if k := strings.IndexByte(inner, '|'); k >= 0 {
+		inner = inner[:k]
+	}
+	inner = strings.TrimSpace(inner)
+
+	target = inner
+	if before, after, ok := strings.Cut(inner, "#"); ok {
+		target = strings.TrimSpace(before)
+		fragment = strings.TrimSpace(after)
+	}
+	return target, fragment
+}
+
+// load resolves the inside of "![[...]]" and returns the path and frontmatter
+// of the embedded note together with the markdown to embed: the whole body,
+// one section or one block.
+func (r embedResolver) load(inner string) (string, map[string]any, []byte, bool) {
+	target, fragment := splitEmbedTarget(inner)
+	if target == "" {
+		return "", nil, nil, false
+	}
+
+	path, ok := r.resolve(target)
+	if !ok {
+		return "", nil, nil, false
+	}
+
+	contentBytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, nil, false
+	}
+
+	front, body := extractFrontmatter(contentBytes)
+	if id, isBlock := strings.CutPrefix(fragment, "^"); isBlock {
+		body, ok = extractMarkdownBlock(body, strings.TrimSpace(id))
+	} else if fragment != "" {
+		body, ok = extractMarkdownSection(body, transformHeadingID(fragment))
+	}
+	if !ok {
+		return "", nil, nil, false
+	}
+
+	return path, front, body, true
+}
+
+func expandMarkdownEmbeds(src []byte, r embedResolver, rootPath string) ([]byte, []string) {
 	if len(src) == 0 {
-		return src
+		return src, nil
 	}
 
 	type segment struct {
 		b      []byte
 		i      int
+		code   [][2]int
 		onDone func()
 	}
 
@@ -188,7 +303,8 @@ func expandMarkdownEmbeds(src []byte, r embedResolver, rootPath string) []byte {
 	depth := 0
 
 	includes := map[string]struct{}{rootPath: {}}
-	stack := []segment{{b: src}}
+	embedded := make(map[string]struct{})
+	stack := []segment{{b: src, code: codeRanges(src)}}
 
 	var out bytes.Buffer
 	out.Grow(len(src))
@@ -211,6 +327,11 @@ func expandMarkdownEmbeds(src []byte, r embedResolver, rootPath string) []byte {
 			seg.i++
 			continue
 		}
+		if end, ok := inCode(seg.code, i); ok {
+			_, _ = out.Write(b[i:end])
+			seg.i = end
+			continue
+		}
 
 		j := i + 3
 		for j+1 < len(b) && !(b[j] == ']' && b[j+1] == ']') {
@@ -231,24 +352,7 @@ func expandMarkdownEmbeds(src []byte, r embedResolver, rootPath string) []byte {
 
 		literal := b[i : j+2]
 
-		if k := strings.IndexByte(inner, '|'); k >= 0 {
-			inner = inner[:k]
-		}
-		inner = strings.TrimSpace(inner)
-
-		target := inner
-		var fragmentID string
-		if before, after, ok := strings.Cut(inner, "#"); ok {
-			target = strings.TrimSpace(before)
-			fragmentID = transformHeadingID(strings.TrimSpace(after))
-		}
-		if target == "" {
-			_, _ = out.Write(literal)
-			seg.i = j + 2
-			continue
-		}
-
-		path, ok := r.resolve(target)
+		path, _, body, ok := r.load(inner)
 		if !ok {
 			_, _ = out.Write(literal)
 			seg.i = j + 2
@@ -260,34 +364,24 @@ func expandMarkdownEmbeds(src []byte, r embedResolver, rootPath string) []byte {
 			continue
 		}
 
-		contentBytes, err := os.ReadFile(path)
-		if err != nil {
-			_, _ = out.Write(literal)
-			seg.i = j + 2
-			continue
-		}
-
-		_, body := extractFrontmatter(contentBytes)
-		if fragmentID != "" {
-			section, ok := extractMarkdownSection(body, fragmentID)
-			if !ok {
-				_, _ = out.Write(literal)
-				seg.i = j + 2
-				continue
-			}
-			body = section
-		}
 		includes[path] = struct{}{}
+		embedded[path] = struct{}{}
 		depth++
 
 		seg.i = j + 2
-		stack = append(stack, segment{b: body, onDone: func() {
+		stack = append(stack, segment{b: body, code: codeRanges(body), onDone: func() {
 			delete(includes, path)
 			depth--
 		}})
 	}
 
-	return out.Bytes()
+	paths := make([]string, 0, len(embedded))
+	for path := range embedded {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return out.Bytes(), paths
 }
 
 func extractMarkdownSection(body []byte, fragmentID string) ([]byte, bool) {
@@ -331,6 +425,99 @@ func extractMarkdownSection(body []byte, fragmentID string) ([]byte, bool) {
 	return []byte(section), true
 }
 
+// extractMarkdownBlock returns the paragraph or list item marked with
+// "^blockID", without the marker. A marker on a line of its own refers to the
+// block right above it.
+func extractMarkdownBlock(body []byte, blockID string) ([]byte, bool) {
+	if blockID == "" || len(body) == 0 {
+		return nil, false
+	}
+
+	lines := strings.Split(string(body), "\n")
+
+	markerLine := -1
+	inFence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if m := blockref.Marker.FindStringSubmatchIndex(line); m != nil && line[m[2]:m[3]] == blockID {
+			markerLine = i
+			break
+		}
+	}
+	if markerLine < 0 {
+		return nil, false
+	}
+
+	marker := blockref.Marker.FindStringIndex(lines[markerLine])
+	lines[markerLine] = strings.TrimRight(lines[markerLine][:marker[0]], " \t")
+
+	end := markerLine
+	if strings.TrimSpace(lines[markerLine]) == "" {
+		end = markerLine - 1
+		for end >= 0 && strings.TrimSpace(lines[end]) == "" {
+			end--
+		}
+		if end < 0 {
+			return nil, false
+		}
+	}
+
+	if isListItemLine(lines[end]) {
+		return []byte(strings.TrimLeft(lines[end], " \t")), true
+	}
+
+	// The paragraph runs up to the line above it that starts another block.
+	// Lines right below a list item continue that item.
+	start := end
+	for start > 0 && !isBlockStartLine(lines[start-1]) {
+		start--
+		if isListItemLine(lines[start]) {
+			break
+		}
+	}
+
+	return []byte(strings.Join(lines[start:end+1], "\n")), true
+}
+
+// isBlockStartLine reports whether line is blank or cannot continue the
+// paragraph above it: a heading, a fence, a thematic break or the underline
+// of a heading.
+func isBlockStartLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		return true
+	}
+	if _, _, ok := parseATXHeading(line); ok || strings.Trim(trimmed, "#") == "" {
+		return true
+	}
+	rule := strings.ReplaceAll(trimmed, " ", "")
+	return len(rule) >= 3 && strings.ContainsRune("-*_=", rune(rule[0])) && strings.Trim(rule, rule[:1]) == ""
+}
+
+func isListItemLine(line string) bool {
+	trimmed := strings.TrimLeft(line, " \t")
+	if len(trimmed) < 2 {
+		return false
+	}
+	switch trimmed[0] {
+	case '-', '*', '+':
+		return trimmed[1] == ' '
+	}
+
+	i := 0
+	for i < len(trimmed) && trimmed[i] >= '0' && trimmed[i] <= '9' {
+		i++
+	}
+	return i > 0 && i+1 < len(trimmed) && (trimmed[i] == '.' || trimmed[i] == ')') && trimmed[i+1] == ' '
+}
+
 func parseATXHeading(line string) (level int, text string, ok bool) {
 	trimmed := strings.TrimLeft(line, " \t")
 	if trimmed == "" || trimmed[0] != '#' {
@@ -378,17 +565,20 @@ func transformHeadingID(text string) string {
 	return id
 }
 
-func buildResolver(entries []content.FileEntry) wikilink.Resolver {
+func buildResolver(entries []content.FileEntry, aliases map[string]content.FileEntry) wikilink.PageResolver {
 	pages := make(map[string]string)
 	shortestPaths := make(map[string]string)
 	baseNamePaths := make(map[string][]string)
 
 	for _, entry := range entries {
+		if entry.IsBase {
+			continue
+		}
+
 		key := strings.TrimSuffix(entry.RelativePath, ".md")
 		key = filepath.ToSlash(key)
 
-		link := utils.PathToSlug(entry.RelativePath)
-		link = "/" + strings.TrimSuffix(link, ".md")
+		link := "/" + utils.PageSlug(entry.RelativePath)
 
 		pages[key] = link
 
@@ -406,19 +596,27 @@ func buildResolver(entries []content.FileEntry) wikilink.Resolver {
 		shortestPaths[base] = pages[shortestPath]
 	}
 
+	aliasLinks := make(map[string]string, len(aliases))
+	for alias, entry := range aliases {
+		aliasLinks[alias] = pages[filepath.ToSlash(strings.TrimSuffix(entry.RelativePath, ".md"))]
+	}
+
 	return wikilink.PageResolver{
 		Pages:         pages,
 		ShortestPaths: shortestPaths,
+		Aliases:       aliasLinks,
 	}
 }
 
-func renderToHTML(source []byte, resolver wikilink.Resolver, embed embedResolver, rootPath string) (string, []types.Link, []types.TocItem, []string, bool, bool) {
+// renderToHTML renders a note. Hashtags link to the tag pages under
+// tagPrefix, the listings of the language of the note.
+func renderToHTML(source []byte, resolver wikilink.Resolver, tagPrefix string) cache.Entry {
 	collector := wikilink.NewLinkCollector(resolver)
 	tagCollector := hashtag.NewCollector()
 	toc := make([]types.TocItem, 0)
-	tagResolver := hashtag.Resolver(tagLinkResolver{})
+	blockIDs := make([]string, 0)
+	tagResolver := hashtag.Resolver(tagLinkResolver{prefix: tagPrefix})
 
-	source = expandMarkdownEmbeds(source, embed, rootPath)
 	context := parser.NewContext()
 
 	md := goldmark.New(
@@ -438,11 +636,14 @@ func renderToHTML(source []byte, resolver wikilink.Resolver, embed embedResolver
 				Resolver:  tagResolver,
 			},
 			&mermaid.Extender{},
+			&queryblock.Extender{},
 			&highlight.Extender{},
 			&callout.Extender{},
 			&anchor.Extender{},
 			&mark.Extender{},
 			&externallink.Extender{},
+			&blockref.Extender{IDs: &blockIDs},
+			&fields.Extender{},
 		),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
@@ -479,7 +680,7 @@ func renderToHTML(source []byte, resolver wikilink.Resolver, embed embedResolver
 
 	var buf bytes.Buffer
 	if err := md.Renderer().Render(&buf, source, doc); err != nil {
-		return "", nil, nil, nil, false, false
+		return cache.Entry{}
 	}
 
 	collectedLinks := collector.GetLinks()
@@ -491,19 +692,32 @@ func renderToHTML(source []byte, resolver wikilink.Resolver, embed embedResolver
 		}
 	}
 
-	return buf.String(), links, toc, tagCollector.Tags(), hasKatex, mermaid.GetHasMermaid(context)
+	return cache.Entry{
+		HTML:            buf.String(),
+		TableOfContents: toc,
+		OutgoingLinks:   links,
+		MissingLinks:    collector.GetMissing(),
+		BlockIDs:        blockIDs,
+		Tags:            tagCollector.Tags(),
+		Tasks:           collectTasks(doc, source),
+		Fields:          fields.Collect(doc),
+		HasKatex:        hasKatex,
+		HasMermaid:      mermaid.GetHasMermaid(context),
+	}
 }
 
-type tagLinkResolver struct{}
+type tagLinkResolver struct {
+	prefix string
+}
 
-func (tagLinkResolver) ResolveHashtag(n *hashtag.Node) ([]byte, error) {
+func (r tagLinkResolver) ResolveHashtag(n *hashtag.Node) ([]byte, error) {
 	tag := strings.TrimSpace(string(n.Tag))
 	tag = strings.TrimPrefix(tag, "#")
 	if tag == "" {
 		return nil, nil
 	}
 
-	return []byte("/tags/" + escapeTagPath(tag)), nil
+	return []byte(r.prefix + "/tags/" + escapeTagPath(tag)), nil
 }
 
 func escapeTagPath(tag string) string {
@@ -630,7 +844,7 @@ func ExtractDescription(front map[string]any, entry content.FileEntry) string {
 func ExtractPermalink(front map[string]any, entry content.FileEntry) string {
 	if t, ok := front["permalink"]; ok {
 		if s, ok := t.(string); ok {
-			url := strings.TrimSuffix(utils.PathToSlug(s), ".md")
+			url := utils.PageSlug(s)
 			url = strings.TrimSpace(url)
 			if url == "" {
 				return ""
@@ -642,7 +856,7 @@ func ExtractPermalink(front map[string]any, entry content.FileEntry) string {
 		}
 	}
 
-	url := strings.TrimSuffix(utils.PathToSlug(entry.RelativePath), ".md")
+	url := utils.PageSlug(entry.RelativePath)
 	url = strings.TrimSpace(url)
 	if url == "" {
 		return ""
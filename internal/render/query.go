@@ -0,0 +1,77 @@
+package render
+
+import (
+	"geode/internal/query"
+	queryblock "geode/internal/render/query"
+	"geode/internal/types"
+	"html"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var queryPlaceholderReg = regexp.MustCompile(`<!--geode-query:([^>]*?)-->\n?`)
+
+// fillQueries replaces the query placeholders of raw, the HTML of page, with
+// the results of the queries. Queries that do not parse show their error.
+func (s *Site) fillQueries(page *types.MetaMarkdown, raw string) string {
+	return queryPlaceholderReg.ReplaceAllStringFunc(raw, func(m string) string {
+		src, err := url.QueryUnescape(queryPlaceholderReg.FindStringSubmatch(m)[1])
+		if err != nil {
+			return ""
+		}
+
+		q, err := query.Parse(src)
+		if err != nil {
+			s.warnOnce("query in " + page.RelativePath + ": " + err.Error())
+			return `<div class="query"><p class="query-error">Invalid query: ` + html.EscapeString(err.Error()) + "</p></div>\n"
+		}
+		return q.Render(query.Env{
+			Pages:   s.Pages,
+			This:    page,
+			Resolve: s.resolveLink,
+		})
+	})
+}
+
+// renderDeferred fills in the base and query placeholders of every page
+// holding some and returns the paths of those pages. They list the other
+// pages, so they are rendered once every page is parsed.
+func (s *Site) renderDeferred() []string {
+	var paths []string
+	now := time.Now()
+
+	for i := range s.Pages {
+		page := &s.Pages[i]
+		raw, ok := s.deferredHTML[page.Path]
+		if !ok {
+			continue
+		}
+
+		page.HTML = s.fillQueries(page, s.fillBases(page, raw, now))
+		paths = append(paths, page.Path)
+	}
+
+	return paths
+}
+
+// trackDeferred remembers the rendered HTML of page while it still holds
+// base or query placeholders.
+func (s *Site) trackDeferred(path, html string) {
+	if strings.Contains(html, basePlaceholderPrefix) || strings.Contains(html, queryblock.PlaceholderPrefix) {
+		s.deferredHTML[path] = html
+	} else {
+		delete(s.deferredHTML, path)
+	}
+}
+
+// warnOnce logs msg unless it was logged before, as deferred pages are
+// rendered again on every change while serving.
+func (s *Site) warnOnce(msg string) {
+	if !s.warned[msg] {
+		s.warned[msg] = true
+		log.Print(msg)
+	}
+}
@@ -0,0 +1,87 @@
+package query
+
+import (
+	"bytes"
+	"net/url"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// PlaceholderPrefix starts the comment left in place of a query block. The
+// results of a query list the other pages, so they are filled in once every
+// page is rendered.
+const PlaceholderPrefix = "<!--geode-query:"
+
+type Extender struct{}
+
+func (e *Extender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(&Transformer{}, 2000),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&Renderer{}, 1000),
+		),
+	)
+}
+
+type Transformer struct{}
+
+func (t *Transformer) Transform(node *ast.Document, reader text.Reader, pc parser.Context) {
+	var blocks []*ast.FencedCodeBlock
+	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && n.Kind() == ast.KindFencedCodeBlock {
+			fenced := n.(*ast.FencedCodeBlock)
+			if string(fenced.Language(reader.Source())) == "query" {
+				blocks = append(blocks, fenced)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, fenced := range blocks {
+		parent := fenced.Parent()
+		parent.ReplaceChild(parent, fenced, &QueryBlock{BlockLine: fenced})
+	}
+}
+
+type QueryBlock struct {
+	ast.BaseBlock
+	BlockLine *ast.FencedCodeBlock
+}
+
+func (n *QueryBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{}, nil)
+}
+
+var KindQueryBlock = ast.NewNodeKind("QueryBlock")
+
+func (n *QueryBlock) Kind() ast.NodeKind {
+	return KindQueryBlock
+}
+
+type Renderer struct{}
+
+func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindQueryBlock, r.Render)
+}
+
+func (r *Renderer) Render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		var buf bytes.Buffer
+		lines := node.(*QueryBlock).BlockLine.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			buf.Write(line.Value(source))
+		}
+		w.WriteString(PlaceholderPrefix + url.QueryEscape(buf.String()) + "-->\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
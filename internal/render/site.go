@@ -0,0 +1,220 @@
+package render
+
+import (
+	"geode/internal/bases"
+	"geode/internal/cache"
+	"geode/internal/canvas"
+	"geode/internal/config"
+	"geode/internal/content"
+	"geode/internal/render/wikilink"
+	"geode/internal/types"
+	"geode/internal/utils"
+	"geode/internal/version"
+	"log"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Site keeps the parsed pages of a vault in memory so that single notes can
+// be re-rendered without walking and rendering the whole vault again.
+type Site struct {
+	Entries []content.FileEntry
+	Pages   []types.MetaMarkdown
+
+	cfg        *config.Config
+	jobs       int
+	embedMode  string
+	resolver   wikilink.PageResolver
+	embedIndex embedResolver
+	cache      *cache.Store
+	cacheKey   string
+
+	bases        map[string]*bases.Base
+	baseIndex    baseIndex
+	deferredHTML map[string]string // page path -> HTML with base or query placeholders
+	warned       map[string]bool
+}
+
+// NewSite renders every markdown entry using cfg.Build.Jobs workers. Pages
+// keep the order of entries, and backlinks are resolved once all pages are
+// rendered, so the result does not depend on the number of workers.
+func NewSite(entries []content.FileEntry, cfg *config.Config) *Site {
+	aliases := buildAliasIndex(entries)
+	resolver := buildResolver(entries, aliases)
+
+	store, err := cache.Open(cfg.Build.Cache)
+	if err != nil {
+		log.Printf("build cache disabled: %v", err)
+		store = nil
+	}
+
+	s := &Site{
+		Entries:      entries,
+		cfg:          cfg,
+		jobs:         cfg.Build.Jobs,
+		embedMode:    cfg.Markdown.Embeds,
+		resolver:     resolver,
+		embedIndex:   buildEmbedIndex(entries, aliases),
+		cache:        store,
+		cacheKey:     cacheKey(cfg, resolver),
+		bases:        loadBases(entries),
+		baseIndex:    buildBaseIndex(entries),
+		deferredHTML: make(map[string]string),
+		warned:       make(map[string]bool),
+	}
+
+	markdown := make([]content.FileEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsMarkdown || entry.IsCanvas {
+			markdown = append(markdown, entry)
+		}
+	}
+
+	results := make([]types.MetaMarkdown, len(markdown))
+	ok := make([]bool, len(markdown))
+	utils.Parallel(s.jobs, len(markdown), func(i int) {
+		page, err := s.parsePage(markdown[i])
+		if err != nil {
+			return
+		}
+		results[i] = page
+		ok[i] = true
+	})
+
+	s.Pages = make([]types.MetaMarkdown, 0, len(markdown))
+	for i, page := range results {
+		if ok[i] {
+			s.Pages = append(s.Pages, page)
+			s.trackDeferred(page.Path, page.HTML)
+		}
+	}
+
+	ResolveBacklinks(s.Pages)
+	s.renderDeferred()
+	return s
+}
+
+// Rerender re-parses the notes at the given paths together with every note
+// that embeds one of them, then refreshes the backlinks of the whole site.
+// Pages embedding a base or holding a query are always rendered again, since
+// any change may show up in their results. It returns the paths of the pages
+// that were rendered again.
+func (s *Site) Rerender(paths []string) []string {
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if strings.HasSuffix(path, bases.Ext) {
+			if b := loadBase(path); b != nil {
+				s.bases[path] = b
+			} else {
+				delete(s.bases, path)
+			}
+		}
+	}
+
+	dirty := s.Dependents(paths)
+
+	indexes := make([]int, 0, len(dirty))
+	for i, page := range s.Pages {
+		if _, ok := dirty[page.Path]; ok {
+			indexes = append(indexes, i)
+		}
+	}
+
+	ok := make([]bool, len(indexes))
+	utils.Parallel(s.jobs, len(indexes), func(i int) {
+		page := s.Pages[indexes[i]]
+		isCanvas := strings.HasSuffix(page.Path, canvas.Ext)
+		entry := content.FileEntry{
+			Path:         page.Path,
+			RelativePath: page.RelativePath,
+			IsMarkdown:   !isCanvas,
+			IsCanvas:     isCanvas,
+		}
+		updated, err := s.parsePage(entry)
+		if err != nil {
+			return
+		}
+		s.Pages[indexes[i]] = updated
+		ok[i] = true
+	})
+
+	rendered := make([]string, 0, len(indexes))
+	for i, idx := range indexes {
+		if ok[i] {
+			rendered = append(rendered, s.Pages[idx].Path)
+			s.trackDeferred(s.Pages[idx].Path, s.Pages[idx].HTML)
+		}
+	}
+
+	ResolveBacklinks(s.Pages)
+
+	for _, path := range s.renderDeferred() {
+		if !slices.Contains(rendered, path) {
+			rendered = append(rendered, path)
+		}
+	}
+	return rendered
+}
+
+// CacheStats returns the build cache hits and misses of this site so far.
+func (s *Site) CacheStats() (hits, misses int64) {
+	return s.cache.Stats()
+}
+
+// cacheKey covers everything besides the note body that changes how a note
+// renders: the cache format, the Geode version, the theme, the embed mode and
+// the set of link targets.
+func cacheKey(cfg *config.Config, resolver wikilink.PageResolver) string {
+	targets := make([]string, 0, len(resolver.Pages)+len(resolver.ShortestPaths))
+	for key, dest := range resolver.Pages {
+		targets = append(targets, "page\x00"+key+"\x00"+dest)
+	}
+	for base, dest := range resolver.ShortestPaths {
+		targets = append(targets, "base\x00"+base+"\x00"+dest)
+	}
+	for alias, dest := range resolver.Aliases {
+		targets = append(targets, "alias\x00"+alias+"\x00"+dest)
+	}
+	sort.Strings(targets)
+
+	return cache.Key(
+		[]byte(cache.Format),
+		[]byte(version.Version),
+		[]byte(cfg.Theme),
+		[]byte(cfg.Markdown.Embeds),
+		[]byte(strings.Join(targets, "\n")),
+	)
+}
+
+// Dependents returns the given paths plus the paths of all pages that embed
+// any of them, directly or through other embeds.
+func (s *Site) Dependents(paths []string) map[string]struct{} {
+	embeddedBy := make(map[string][]string)
+	for _, page := range s.Pages {
+		for _, embed := range page.Embeds {
+			key := filepath.Clean(embed)
+			embeddedBy[key] = append(embeddedBy[key], page.Path)
+		}
+	}
+
+	dirty := make(map[string]struct{}, len(paths))
+	queue := make([]string, 0, len(paths))
+	for _, path := range paths {
+		queue = append(queue, filepath.Clean(path))
+	}
+
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+
+		if _, seen := dirty[path]; seen {
+			continue
+		}
+		dirty[path] = struct{}{}
+		queue = append(queue, embeddedBy[path]...)
+	}
+
+	return dirty
+}
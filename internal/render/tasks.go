@@ -0,0 +1,116 @@
+package render
+
+import (
+	"geode/internal/render/fields"
+	hashtag "geode/internal/render/tags"
+	"geode/internal/render/wikilink"
+	"geode/internal/types"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// Due dates are written the Tasks plugin way, "📅 2025-01-01", or as a
+// Dataview field, "[due:: 2025-01-01]".
+var (
+	taskDueEmojiReg = regexp.MustCompile(`📅\s*(\d{4}-\d{2}-\d{2})`)
+	taskSpaceReg    = regexp.MustCompile(`\s+`)
+)
+
+// collectTasks returns the task list items of doc in document order, with
+// the heading each one is under.
+func collectTasks(doc ast.Node, source []byte) []types.Task {
+	var tasks []types.Task
+	var heading, anchor string
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Heading:
+			heading, anchor = headingText(n, source), headingID(n)
+			return ast.WalkSkipChildren, nil
+
+		case *ast.ListItem:
+			block := n.FirstChild()
+			if block == nil {
+				return ast.WalkContinue, nil
+			}
+			box, ok := block.FirstChild().(*extast.TaskCheckBox)
+			if !ok {
+				return ast.WalkContinue, nil
+			}
+
+			text, tags, due := taskText(block, source)
+			task := types.Task{
+				Checked:   box.IsChecked,
+				Heading:   heading,
+				HeadingID: anchor,
+				Due:       due,
+				Tags:      tags,
+			}
+			if m := taskDueEmojiReg.FindStringSubmatch(text); m != nil {
+				task.Due = m[1]
+			}
+			text = taskDueEmojiReg.ReplaceAllString(text, "")
+			task.Text = strings.TrimSpace(taskSpaceReg.ReplaceAllString(text, " "))
+
+			tasks = append(tasks, task)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return tasks
+}
+
+// taskText returns the plain text of the first block of a task, the tags in
+// it and its due field. Inline fields are left out of the text.
+func taskText(block ast.Node, source []byte) (string, []string, string) {
+	var b strings.Builder
+	var tags []string
+	due := ""
+
+	ast.Walk(block, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Text:
+			b.Write(n.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		case *ast.CodeSpan:
+			for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+				if t, ok := c.(*ast.Text); ok {
+					b.Write(t.Value(source))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		case *wikilink.Node:
+			if n.ChildCount() == 0 {
+				b.Write(n.Target)
+			}
+		case *hashtag.Node:
+			tag := strings.TrimPrefix(string(n.Tag), "#")
+			b.WriteString("#" + tag)
+			tags = append(tags, tag)
+			return ast.WalkSkipChildren, nil
+		case *fields.Node:
+			if strings.EqualFold(n.Key, "due") {
+				due = n.Value
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return b.String(), tags, due
+}
@@ -7,6 +7,7 @@ import (
 type LinkCollector struct {
 	Renderer Resolver
 	links    []CollectedLink
+	missing  []string
 	mu       sync.Mutex
 	hasDest  sync.Map
 }
@@ -41,6 +42,27 @@ func (c *LinkCollector) CollectLink(n *Node, dest []byte, src []byte) {
 	})
 }
 
+// CollectMissing records a link target that did not resolve to any page.
+func (c *LinkCollector) CollectMissing(target string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.missing {
+		if t == target {
+			return
+		}
+	}
+	c.missing = append(c.missing, target)
+}
+
+func (c *LinkCollector) GetMissing() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]string, len(c.missing))
+	copy(result, c.missing)
+	return result
+}
+
 func (c *LinkCollector) GetLinks() []CollectedLink {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -53,4 +75,5 @@ func (c *LinkCollector) Reset() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.links = c.links[:0]
+	c.missing = c.missing[:0]
 }
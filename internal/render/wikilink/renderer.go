@@ -44,7 +44,7 @@ func (r *Renderer) enter(w util.BufWriter, n *Node, src []byte) (ast.WalkStatus,
 		return ast.WalkStop, fmt.Errorf("resolve %q: %w", n.Target, err)
 	}
 	if len(dest) == 0 {
-		return ast.WalkContinue, nil
+		return r.enterMissing(w, n)
 	}
 
 	if r.Collector != nil {
@@ -82,6 +82,31 @@ func (r *Renderer) enter(w util.BufWriter, n *Node, src []byte) (ast.WalkStatus,
 	return ast.WalkSkipChildren, nil
 }
 
+// enterMissing renders a link whose target does not exist as a styled link to
+// its entry on the missing links page.
+func (r *Renderer) enterMissing(w util.BufWriter, n *Node) (ast.WalkStatus, error) {
+	target := strings.TrimSpace(string(n.Target))
+	if target == "" {
+		return ast.WalkContinue, nil
+	}
+
+	if r.Collector != nil {
+		r.Collector.CollectMissing(target)
+	}
+
+	r.hasDest.Store(n, struct{}{})
+	_, _ = w.WriteString(`<a class="wikilink-missing" href="`)
+	href := MissingPage
+	if anchor := MissingAnchor(target); anchor != "" {
+		href += "#" + anchor
+	}
+	_, _ = w.Write(util.URLEscape([]byte(href), true))
+	_, _ = w.WriteString(`" data-target="`)
+	_, _ = w.Write(util.EscapeHTML([]byte(target)))
+	_, _ = w.WriteString(`">`)
+	return ast.WalkContinue, nil
+}
+
 func parseWidth(label []byte) (int, bool) {
 	w, err := strconv.Atoi(strings.TrimSpace(string(label)))
 	if err != nil || w <= 0 {
@@ -6,6 +6,15 @@ import (
 	"unicode"
 )
 
+// MissingPage is the URL of the page listing link targets that do not exist.
+const MissingPage = "/_missing"
+
+// MissingAnchor returns the fragment under which target is listed on the
+// missing links page.
+func MissingAnchor(target string) string {
+	return transformHeadingID(target)
+}
+
 type Resolver interface {
 	ResolveWikilink(*Node) (destination []byte, err error)
 }
@@ -13,6 +22,8 @@ type Resolver interface {
 type PageResolver struct {
 	Pages         map[string]string
 	ShortestPaths map[string]string
+	// Aliases maps lower-cased frontmatter aliases to page links.
+	Aliases map[string]string
 }
 
 func (r PageResolver) ResolveWikilink(n *Node) ([]byte, error) {
@@ -36,16 +47,31 @@ func (r PageResolver) ResolveWikilink(n *Node) ([]byte, error) {
 		return withFragment(dest, n), nil
 	}
 
+	// Alias, case-insensitive like Obsidian
+	if dest, ok := r.Aliases[strings.ToLower(target)]; ok {
+		return withFragment(dest, n), nil
+	}
+
 	return nil, nil
 }
 
 func withFragment(dest string, n *Node) []byte {
 	if len(n.Fragment) > 0 {
-		dest += "#" + transformHeadingID(string(n.Fragment))
+		dest += "#" + FragmentID(string(n.Fragment))
 	}
 	return []byte(dest)
 }
 
+// FragmentID returns the HTML id a link fragment points to: "^id" for block
+// references and the heading id otherwise.
+func FragmentID(fragment string) string {
+	fragment = strings.TrimSpace(fragment)
+	if id, ok := strings.CutPrefix(fragment, "^"); ok {
+		return "^" + strings.TrimSpace(id)
+	}
+	return transformHeadingID(fragment)
+}
+
 func transformHeadingID(text string) string {
 	text = strings.TrimSpace(text)
 	text = strings.TrimLeft(text, "#")
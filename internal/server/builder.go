@@ -0,0 +1,394 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"geode/internal/build"
+	"geode/internal/config"
+	"geode/internal/content"
+	"geode/internal/pagefind"
+	"geode/internal/render"
+	"geode/internal/types"
+	"geode/internal/utils"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Builder builds a site and remembers the rendered pages so that later
+// changes can be applied incrementally instead of rebuilding everything.
+type Builder struct {
+	dir  string
+	cfg  *config.Config
+	live bool
+
+	mu       sync.Mutex
+	entries  []content.FileEntry
+	site     *render.Site
+	fileTree *types.FileTree
+
+	mostReferenced []types.Link
+	translations   map[string][]build.Translation
+}
+
+// mostReferencedLimit is the number of notes listed as most referenced on
+// the index page.
+const mostReferencedLimit = 10
+
+func NewBuilder(dir string, cfg *config.Config, live bool) *Builder {
+	return &Builder{
+		dir:  dir,
+		cfg:  cfg,
+		live: live,
+	}
+}
+
+func Rebuild(dir string, cfg *config.Config, live bool) error {
+	return NewBuilder(dir, cfg, live).Build()
+}
+
+func (b *Builder) Build() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.build()
+}
+
+func (b *Builder) build() error {
+	if err := CleanPublicDir(b.cfg.Build.Output, b.dir); err != nil {
+		return fmt.Errorf("clean output dir: %w", err)
+	}
+
+	entries, err := content.GetAllMarkdownAndAssets(b.dir, b.cfg)
+	if err != nil {
+		return err
+	}
+
+	filtered := content.FilterEntries(entries, b.cfg)
+
+	site := render.NewSite(filtered, b.cfg)
+	if b.cfg.Build.Cache != "" {
+		hits, misses := site.CacheStats()
+		fmt.Printf("Cache: %d hits, %d misses (%s)\n", hits, misses, b.cfg.Build.Cache)
+	}
+
+	fileTree := render.BuildFileTree(site.Pages)
+	b.mostReferenced = build.MostReferencedLinks(b.cfg, site.Pages, mostReferencedLimit)
+	b.translations = build.Translations(b.cfg, site.Pages)
+
+	if err := b.writePages(site.Pages, fileTree); err != nil {
+		return err
+	}
+
+	if err := b.writeListings(site.Pages, nil, fileTree); err != nil {
+		return err
+	}
+
+	if err := CopyThemeAssets(b.cfg); err != nil {
+		return err
+	}
+
+	if err := CopyContentAssets(filtered, b.cfg); err != nil {
+		return err
+	}
+
+	if err := b.finish(site.Pages); err != nil {
+		return err
+	}
+
+	b.entries = filtered
+	b.site = site
+	b.fileTree = fileTree
+
+	if b.live {
+		fmt.Println("Site rebuilt.")
+	} else {
+		fmt.Println("Build completed.")
+	}
+
+	return nil
+}
+
+type pageState struct {
+	Title     string
+	Link      string
+	Lang      string
+	Tags      []string
+	Backlinks []types.Link
+}
+
+// Update applies a set of changed files to the last build. Notes are
+// re-rendered together with the notes that embed them, and only the pages
+// whose output depends on them are written again. Anything that changes the
+// set of files or the theme falls back to a full build.
+func (b *Builder) Update(paths []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.site == nil {
+		return b.build()
+	}
+
+	themesPath := filepath.Join("themes", b.cfg.Theme)
+	for _, path := range paths {
+		if rel, err := filepath.Rel(themesPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return b.build()
+		}
+	}
+
+	entries, err := content.GetAllMarkdownAndAssets(b.dir, b.cfg)
+	if err != nil {
+		return err
+	}
+
+	filtered := content.FilterEntries(entries, b.cfg)
+	if !sameEntries(b.entries, filtered) {
+		return b.build()
+	}
+
+	changed := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		changed[filepath.Clean(path)] = struct{}{}
+	}
+
+	var notes []string
+	var assets []content.FileEntry
+	for _, entry := range filtered {
+		if _, ok := changed[filepath.Clean(entry.Path)]; !ok {
+			continue
+		}
+		if entry.IsMarkdown || entry.IsCanvas || entry.IsBase {
+			notes = append(notes, entry.Path)
+		} else if entry.IsAsset {
+			assets = append(assets, entry)
+		}
+	}
+
+	if err := CopyContentAssets(assets, b.cfg); err != nil {
+		return err
+	}
+
+	if len(notes) == 0 {
+		return nil
+	}
+
+	before := make(map[string]pageState, len(b.site.Pages))
+	for _, page := range b.site.Pages {
+		before[page.Path] = pageState{
+			Title:     page.Title,
+			Link:      page.Link,
+			Lang:      page.Lang,
+			Tags:      page.Tags,
+			Backlinks: page.Backlinks,
+		}
+	}
+
+	rendered := b.site.Rerender(notes)
+	pages := b.site.Pages
+
+	titleChanged := false
+	affectedTags := make(map[string]struct{})
+	for _, path := range rendered {
+		prev := before[path]
+		for _, tag := range prev.Tags {
+			affectedTags[tag] = struct{}{}
+		}
+	}
+
+	dirty := make(map[string]struct{}, len(rendered))
+	for _, path := range rendered {
+		dirty[path] = struct{}{}
+	}
+
+	for _, page := range pages {
+		prev := before[page.Path]
+		if prev.Link != page.Link || prev.Lang != page.Lang {
+			return b.build()
+		}
+
+		if _, ok := dirty[page.Path]; ok {
+			if prev.Title != page.Title {
+				titleChanged = true
+			}
+			for _, tag := range page.Tags {
+				affectedTags[tag] = struct{}{}
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(prev.Backlinks, page.Backlinks) {
+			dirty[page.Path] = struct{}{}
+		}
+	}
+
+	// A new translation changes the language switcher of every version.
+	if !reflect.DeepEqual(build.Translations(b.cfg, pages), b.translations) {
+		return b.build()
+	}
+
+	if mostReferenced := build.MostReferencedLinks(b.cfg, pages, mostReferencedLimit); !reflect.DeepEqual(mostReferenced, b.mostReferenced) {
+		b.mostReferenced = mostReferenced
+		for _, page := range pages {
+			if utils.PageSlug(page.RelativePath) == "index" {
+				dirty[page.Path] = struct{}{}
+			}
+		}
+	}
+
+	fileTree := b.fileTree
+	toWrite := pages
+	var tags []string
+
+	if titleChanged {
+		fileTree = render.BuildFileTree(pages)
+	} else {
+		toWrite = make([]types.MetaMarkdown, 0, len(dirty))
+		for _, page := range pages {
+			if _, ok := dirty[page.Path]; ok {
+				toWrite = append(toWrite, page)
+			}
+		}
+
+		tags = make([]string, 0, len(affectedTags))
+		for tag := range affectedTags {
+			tags = append(tags, tag)
+		}
+	}
+
+	if err := b.writePages(toWrite, fileTree); err != nil {
+		return err
+	}
+
+	if err := b.writeListings(pages, tags, fileTree); err != nil {
+		return err
+	}
+
+	if err := b.finish(pages); err != nil {
+		return err
+	}
+
+	b.fileTree = fileTree
+
+	fmt.Printf("Rebuilt %d of %d pages.\n", len(toWrite), len(pages))
+	return nil
+}
+
+func (b *Builder) writePages(pages []types.MetaMarkdown, fileTree *types.FileTree) error {
+	writer, err := build.NewHTMLWriter(b.cfg)
+	if err != nil {
+		return fmt.Errorf("init html writer: %w", err)
+	}
+	writer.MostReferenced = b.mostReferenced
+	writer.Translations = b.translations
+
+	errs := make([]error, len(pages))
+	utils.Parallel(b.cfg.Build.Jobs, len(pages), func(i int) {
+		if err := writer.Write(pages[i], b.live, fileTree); err != nil {
+			errs[i] = fmt.Errorf("write html %s: %w", pages[i].RelativePath, err)
+		}
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeListings writes the tag index and the tag pages of every language, the
+// redirects, the missing links page, the site graph, the tasks page, the
+// folder pages and the 404 page. A nil tag list rebuilds every tag page;
+// otherwise only the listed tags and the site-wide listings are written again.
+func (b *Builder) writeListings(pages []types.MetaMarkdown, tags []string, fileTree *types.FileTree) error {
+	for _, lang := range b.cfg.LanguageCodes() {
+		langPages := build.LanguagePages(pages, lang)
+
+		if err := build.BuildTagsIndex(b.cfg, lang, langPages, b.live, fileTree); err != nil {
+			return fmt.Errorf("build tags index: %w", err)
+		}
+
+		if tags == nil {
+			if err := build.BuildTagPages(b.cfg, lang, langPages, b.live, fileTree); err != nil {
+				return fmt.Errorf("build tag pages: %w", err)
+			}
+		} else if err := build.BuildTagPagesFor(b.cfg, lang, langPages, tags, b.live, fileTree); err != nil {
+			return fmt.Errorf("build tag pages: %w", err)
+		}
+	}
+
+	if err := build.BuildRedirects(b.cfg, pages); err != nil {
+		return fmt.Errorf("build redirects: %w", err)
+	}
+
+	if err := build.BuildMissingPage(b.cfg, pages, b.live, fileTree); err != nil {
+		return fmt.Errorf("build missing links page: %w", err)
+	}
+
+	if err := build.BuildGraphPage(b.cfg, pages, b.live, fileTree); err != nil {
+		return fmt.Errorf("build graph: %w", err)
+	}
+
+	if err := build.BuildTasksPage(b.cfg, pages, b.live, fileTree); err != nil {
+		return fmt.Errorf("build tasks page: %w", err)
+	}
+
+	if err := build.BuildFolderPages(b.cfg, pages, b.live, fileTree); err != nil {
+		return fmt.Errorf("build folder pages: %w", err)
+	}
+
+	if tags != nil {
+		return nil
+	}
+
+	if err := build.Build404(b.cfg, b.live, fileTree); err != nil {
+		return fmt.Errorf("build 404 page: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Builder) finish(pages []types.MetaMarkdown) error {
+	// Build pagefind index
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if err := pagefind.Run(ctx, b.cfg.Build.Output); err != nil {
+		return fmt.Errorf("build pagefind index: %w", err)
+	}
+
+	// Build sitemap
+	fmt.Println(b.cfg.Site.BaseURL)
+	if err := build.BuildSitemap(b.cfg, pages); err != nil {
+		return fmt.Errorf("build sitemap: %w", err)
+	}
+
+	if err := build.BuildFeeds(b.cfg, pages); err != nil {
+		return fmt.Errorf("build feeds: %w", err)
+	}
+
+	return nil
+}
+
+func sameEntries(a, b []content.FileEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Path != b[i].Path ||
+			a[i].IsMarkdown != b[i].IsMarkdown ||
+			a[i].IsAsset != b[i].IsAsset ||
+			a[i].IsBase != b[i].IsBase ||
+			a[i].IsCanvas != b[i].IsCanvas ||
+			!slices.Equal(a[i].Aliases, b[i].Aliases) {
+			return false
+		}
+	}
+
+	return true
+}
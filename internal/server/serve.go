@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"geode/internal/config"
 	"log"
 	"net/http"
 	"os"
@@ -10,32 +11,47 @@ import (
 	"strings"
 )
 
-func ServePublic(port int) {
-	fs := http.FileServer(http.Dir("public"))
+// ServePublic serves the site built into build.output under site.base_path,
+// the way static hosts serve it once deployed: pages are url.html or, with the
+// directory url style, url/index.html.
+func ServePublic(cfg *config.Config, port int) {
+	dir, basePath := cfg.Build.Output, cfg.Site.BasePath
+	fs := http.FileServer(http.Dir(dir))
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	site := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := filepath.Clean(r.URL.Path)
 
 		if path == "/" {
-			http.ServeFile(w, r, "public/index.html")
+			http.ServeFile(w, r, filepath.Join(dir, "index.html"))
 			return
 		}
 
 		rel := strings.TrimPrefix(path, "/")
-		htmlPath := filepath.Join("public", rel) + ".html"
-
-		if fi, err := os.Stat(htmlPath); err == nil && !fi.IsDir() {
-			http.ServeFile(w, r, htmlPath)
-			return
+		if cfg.Build.URLStyle == config.URLStyleDirectory {
+			indexPath := filepath.Join(dir, rel, "index.html")
+			if fi, err := os.Stat(indexPath); err == nil && !fi.IsDir() {
+				if !strings.HasSuffix(r.URL.Path, "/") {
+					http.Redirect(w, r, basePath+path+"/", http.StatusMovedPermanently)
+					return
+				}
+				http.ServeFile(w, r, indexPath)
+				return
+			}
+		} else {
+			htmlPath := filepath.Join(dir, rel) + ".html"
+			if fi, err := os.Stat(htmlPath); err == nil && !fi.IsDir() {
+				http.ServeFile(w, r, htmlPath)
+				return
+			}
 		}
 
-		fullPath := filepath.Join("public", rel)
+		fullPath := filepath.Join(dir, rel)
 		if _, err := os.Stat(fullPath); err == nil {
 			fs.ServeHTTP(w, r)
 			return
 		}
 
-		if content, err := os.ReadFile("public/404.html"); err == nil {
+		if content, err := os.ReadFile(filepath.Join(dir, "404.html")); err == nil {
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.WriteHeader(http.StatusNotFound)
 			w.Write(content)
@@ -45,6 +61,19 @@ func ServePublic(port int) {
 		}
 	})
 
+	if basePath == "" {
+		http.Handle("/", site)
+	} else {
+		http.Handle(basePath+"/", http.StripPrefix(basePath, site))
+		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/" {
+				http.Redirect(w, r, basePath+"/", http.StatusFound)
+				return
+			}
+			http.NotFound(w, r)
+		})
+	}
+
 	http.HandleFunc("/_reload", sseHandler)
 
 	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(port), nil))
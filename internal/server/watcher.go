@@ -1,18 +1,15 @@
 package server
 
 import (
-	"context"
 	"fmt"
-	"geode/internal/build"
 	"geode/internal/config"
 	"geode/internal/content"
-	"geode/internal/pagefind"
-	"geode/internal/render"
 	"geode/internal/utils"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -20,16 +17,16 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
-func WatchAndRebuild(contentDir string, cfg *config.Config) {
+func WatchAndRebuild(b *Builder) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer watcher.Close()
 
-	themesPath := filepath.Join("themes", cfg.Theme)
+	themesPath := filepath.Join("themes", b.cfg.Theme)
 
-	if err := watchRecursive(watcher, contentDir); err != nil {
+	if err := watchRecursive(watcher, b.dir); err != nil {
 		log.Fatal(err)
 	}
 	if err := watchRecursive(watcher, themesPath); err != nil {
@@ -41,6 +38,7 @@ func WatchAndRebuild(contentDir string, cfg *config.Config) {
 	var (
 		debounce *time.Timer
 		mu       sync.Mutex
+		changed  = make(map[string]struct{})
 	)
 
 	for {
@@ -66,17 +64,27 @@ func WatchAndRebuild(contentDir string, cfg *config.Config) {
 				}
 			}
 
-			event := e
-
 			mu.Lock()
+			changed[filepath.Clean(e.Name)] = struct{}{}
 			if debounce != nil {
 				debounce.Stop()
 			}
 
 			debounce = time.AfterFunc(200*time.Millisecond, func() {
-				fmt.Println("Changed:", event.Name)
+				mu.Lock()
+				paths := make([]string, 0, len(changed))
+				for path := range changed {
+					paths = append(paths, path)
+				}
+				clear(changed)
+				mu.Unlock()
 
-				if err := Rebuild(contentDir, cfg, true); err != nil {
+				sort.Strings(paths)
+				for _, path := range paths {
+					fmt.Println("Changed:", path)
+				}
+
+				if err := b.Update(paths); err != nil {
 					log.Println("Rebuild error:", err)
 					return
 				}
@@ -106,83 +114,34 @@ func watchRecursive(w *fsnotify.Watcher, root string) error {
 	})
 }
 
-func Rebuild(dir string, cfg *config.Config, live bool) error {
-	if err := CleanPublicDir(); err != nil {
-		return fmt.Errorf("clean public dir: %w", err)
+// CleanPublicDir empties the output directory. It refuses to remove the
+// working directory, one of its parents or a directory holding the content.
+func CleanPublicDir(dir, contentDir string) error {
+	if within(".", dir) || within(contentDir, dir) {
+		return fmt.Errorf("refusing to clean output directory %q", dir)
 	}
 
-	entries, err := content.GetAllMarkdownAndAssets(dir, cfg)
+	err := os.RemoveAll(dir)
 	if err != nil {
 		return err
 	}
 
-	filtered := content.FilterEntries(entries, cfg)
-
-	pages := render.ParsingMarkdown(filtered)
-
-	fileTree := render.BuildFileTree(pages)
+	return os.MkdirAll(dir, 0o755)
+}
 
-	writer, err := build.NewHTMLWriter(cfg)
+// within reports whether path is dir or a directory inside it.
+func within(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return fmt.Errorf("init html writer: %w", err)
+		return false
 	}
-
-	for _, page := range pages {
-		if err := writer.Write(page, live, fileTree); err != nil {
-			return fmt.Errorf("write html %s: %w", page.RelativePath, err)
-		}
-	}
-
-	if err := build.BuildTagsIndex(cfg, pages, live, fileTree); err != nil {
-		return fmt.Errorf("build tags index: %w", err)
-	}
-	if err := build.BuildTagPages(cfg, pages, live, fileTree); err != nil {
-		return fmt.Errorf("build tag pages: %w", err)
-	}
-
-	// TODO: Build default directory pages
-	if err := build.Build404(cfg, live, fileTree); err != nil {
-		return fmt.Errorf("build 404 page: %w", err)
-	}
-
-	if err := CopyThemeAssets(cfg); err != nil {
-		return err
-	}
-
-	if err := CopyContentAssets(filtered, cfg); err != nil {
-		return err
-	}
-
-	// Build pagefind index
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-	defer cancel()
-
-	if err := pagefind.Run(ctx, cfg.Build.Output); err != nil {
-		return fmt.Errorf("build pagefind index: %w", err)
-	}
-
-	// Build sitemap
-	fmt.Println(cfg.Site.BaseURL)
-	if err := build.BuildSitemap(cfg, pages); err != nil {
-		return fmt.Errorf("build sitemap: %w", err)
-	}
-
-	if live {
-		fmt.Println("Site rebuilt.")
-	} else {
-		fmt.Println("Build completed.")
-	}
-
-	return nil
-}
-
-func CleanPublicDir() error {
-	err := os.RemoveAll("public")
+	absDir, err := filepath.Abs(dir)
 	if err != nil {
-		return err
+		return false
 	}
 
-	return os.MkdirAll("public", 0o755)
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
 func CopyContentAssets(entries []content.FileEntry, cfg *config.Config) error {
@@ -200,7 +159,7 @@ func CopyContentAssets(entries []content.FileEntry, cfg *config.Config) error {
 		relPathNoExt = strings.ReplaceAll(relPathNoExt, "\\", "/")
 
 		normalizedPath := utils.PathToSlug(relPathNoExt) + ext
-		destPath := filepath.Join("public", normalizedPath)
+		destPath := filepath.Join(cfg.Build.Output, normalizedPath)
 
 		if err := copyFile(entry.Path, destPath); err != nil {
 			return fmt.Errorf("copy asset %s: %w", entry.RelativePath, err)
@@ -212,7 +171,7 @@ func CopyContentAssets(entries []content.FileEntry, cfg *config.Config) error {
 
 func CopyThemeAssets(cfg *config.Config) error {
 	srcDir := filepath.Join("themes", cfg.Theme, "assets")
-	return copyDirRecursive(srcDir, "public")
+	return copyDirRecursive(srcDir, cfg.Build.Output)
 }
 
 func shouldIgnoreAsset(path string, patterns []string) bool {
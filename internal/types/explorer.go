@@ -5,5 +5,6 @@ type FileTree struct {
 	Path     string      `json:"path"`
 	Title    string      `json:"title,omitempty"`
 	Link     string      `json:"permalink,omitempty"`
+	Lang     string      `json:"lang,omitempty"`
 	Children []*FileTree `json:"children,omitempty"`
 }
@@ -1,9 +1,25 @@
 package types
 
+const (
+	GraphNodeNote = "note"
+	GraphNodeTag  = "tag"
+)
+
 type GraphNode struct {
-	ID    string `json:"id"`
-	Title string `json:"title"`
-	URL   string `json:"url"`
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	URL       string   `json:"url"`
+	Type      string   `json:"type"`
+	Tags      []string `json:"tags,omitempty"`
+	Folder    string   `json:"folder,omitempty"`
+	WordCount int      `json:"wordCount,omitempty"`
+	Backlinks int      `json:"backlinks"`
+
+	// Link metrics over note-to-note links, see build.ComputeGraphMetrics.
+	InDegree  int     `json:"inDegree"`
+	OutDegree int     `json:"outDegree"`
+	PageRank  float64 `json:"pageRank"`
+	Component int     `json:"component"`
 }
 
 type GraphLink struct {
@@ -17,14 +17,32 @@ type MetaMarkdown struct {
 	Link            string
 	Title           string
 	Frontmatter     map[string]any
+	Fields          map[string]any // frontmatter and inline fields
 	Tags            []string
 	ReadingTime     int
 	WordCount       int
 	HTML            string
 	OutgoingLinks   []Link
 	Backlinks       []Link
+	MissingLinks    []string
 	TableOfContents []TocItem
+	BlockIDs        []string
+	Tasks           []Task
 	HasKatex        bool
 	HasMermaid      bool
 	Description     string
+	Embeds          []string
+	Lang            string
+	TranslationKey  string
+}
+
+// Task is a "- [ ]" or "- [x]" list item of a note. Text is plain text
+// without the due date, and Heading is the last heading above the task.
+type Task struct {
+	Text      string   `json:"text"`
+	Checked   bool     `json:"checked"`
+	Heading   string   `json:"heading,omitempty"`
+	HeadingID string   `json:"headingId,omitempty"`
+	Due       string   `json:"due,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
 }
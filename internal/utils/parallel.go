@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Parallel calls fn for every index in [0, n) using at most jobs goroutines.
+// A jobs value below one uses one worker per CPU.
+func Parallel(jobs, n int, fn func(i int)) {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+	if jobs > n {
+		jobs = n
+	}
+
+	if jobs <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+}
@@ -27,3 +27,13 @@ func PathToSlug(path string) string {
 
 	return out
 }
+
+// PageSlug returns the slug of the note or canvas at relativePath, without
+// its extension.
+func PageSlug(relativePath string) string {
+	slug := PathToSlug(relativePath)
+	if s, ok := strings.CutSuffix(slug, ".md"); ok {
+		return s
+	}
+	return strings.TrimSuffix(slug, ".canvas")
+}
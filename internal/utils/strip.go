@@ -32,6 +32,7 @@ var (
 	wikiLinkReg      = regexp.MustCompile(`\[\[(.*?)\]\]`)
 	embedWikiLinkReg = regexp.MustCompile(`\!\[\[(.*?)\]\]`)
 	emptyLineReg     = regexp.MustCompile(`\n{2,}`)
+	blockIDReg       = regexp.MustCompile(`(?m)(^|[ \t])\^[A-Za-z0-9-]+[ \t]*$`)
 )
 
 func StripMarkdown(s string) string {
@@ -62,6 +63,7 @@ func StripMarkdown(s string) string {
 	res = atxHeaderReg6.ReplaceAllString(res, "\n\n")
 	res = wikiLinkReg.ReplaceAllString(res, "$1")
 	res = embedWikiLinkReg.ReplaceAllString(res, "")
+	res = blockIDReg.ReplaceAllString(res, "")
 	res = emptyLineReg.ReplaceAllString(res, "")
 
 	return res
@@ -37,7 +37,13 @@ document.addEventListener("DOMContentLoaded", () => {
     const li = folder.parentElement;
     if (!li) return;
 
-    li.classList.toggle("open");
+    // The folder name links to the folder page, which opens with the folder
+    // expanded.
+    if (e.target.closest(".folder-name")) {
+      li.classList.add("open");
+    } else {
+      li.classList.toggle("open");
+    }
 
     const key = li.getAttribute("data-node-key");
     if (key) {
@@ -1,16 +1,40 @@
 const container = document.getElementById("graph-container");
-const graphData = JSON.parse(container.dataset.graph);
-const currentPage = container.dataset.currentPage;
+const currentPage = container ? container.dataset.currentPage : "";
+const isFullGraph = !currentPage;
+
+const showTagsInput = document.getElementById("graph-show-tags");
+const folderSelect = document.getElementById("graph-folder");
 
 let currentTheme = localStorage.getItem("theme") || "light";
 
+let nodeCount = 1;
+
+// importance compares the PageRank of a node with the average of the graph,
+// clamped so that hubs stand out without hiding everything else.
+const importance = (node) =>
+  node.pageRank ? Math.min(Math.max(node.pageRank * nodeCount, 0.5), 4) : 1;
+
 const drawNode = (node, ctx, globalScale) => {
   const label = node.title || node.id;
-  const fontSize = 12 / globalScale;
+  const fontSize = (10 + 2 * importance(node)) / globalScale;
   ctx.font = `${fontSize}px Inter, sans-serif`;
 
   ctx.textAlign = "center";
   ctx.textBaseline = "middle";
+
+  if (isFullGraph) {
+    ctx.beginPath();
+    const radius = node.type === "tag" ? 3 : 3 * Math.sqrt(importance(node)) + 1;
+    ctx.arc(node.x, node.y, radius, 0, 2 * Math.PI);
+    ctx.fillStyle = node.color;
+    ctx.fill();
+
+    if (globalScale < 1.5) return;
+    ctx.fillStyle = currentTheme === "dark" ? "#fff" : "#111";
+    ctx.fillText(label, node.x, node.y + 4 + 3 * Math.sqrt(importance(node)));
+    return;
+  }
+
   ctx.fillStyle = currentTheme === "dark" ? "#fff" : "#111";
   ctx.fillText(label, node.x, node.y);
 };
@@ -18,22 +42,112 @@ const drawNode = (node, ctx, globalScale) => {
 const getLinkColor = () =>
   currentTheme === "dark" ? "rgba(255, 255, 255, 0.4)" : "rgba(0, 0, 0, 0.4)";
 
-const graph = ForceGraph()(container)
-  .graphData(graphData)
-  .nodeId("id")
-  .nodeLabel("title")
-  .nodeAutoColorBy("id")
-  .nodeRelSize(6)
-  .nodeCanvasObjectMode(() => "replace")
-  .nodeCanvasObject(drawNode)
-  .linkColor(getLinkColor)
-  .onNodeClick((node) => {
-    window.location.href = node.url;
-  })
-  .width(280)
-  .height(250);
-
-document.addEventListener("theme-change", (event) => {
-  currentTheme = event.detail;
-  graph.nodeCanvasObject(drawNode).linkColor(getLinkColor);
-});
+// localGraph keeps the nodes up to data-depth links away from the current
+// page, following outgoing links, backlinks or both.
+const localGraph = (data) => {
+  const depth = parseInt(container.dataset.depth || "1", 10);
+  const direction = container.dataset.links || "both";
+  const showTags = container.dataset.tags === "true";
+
+  const types = new Map(data.nodes.map((node) => [node.id, node.type]));
+  const visible = (id) => types.has(id) && (showTags || types.get(id) !== "tag");
+
+  const ids = new Set([currentPage]);
+  let frontier = new Set([currentPage]);
+  for (let i = 0; i < depth && frontier.size > 0; i++) {
+    const next = new Set();
+    data.links.forEach((link) => {
+      if (direction !== "backlinks" && frontier.has(link.source)) {
+        next.add(link.target);
+      }
+      if (direction !== "outgoing" && frontier.has(link.target)) {
+        next.add(link.source);
+      }
+    });
+    frontier = new Set([...next].filter((id) => visible(id) && !ids.has(id)));
+    frontier.forEach((id) => ids.add(id));
+  }
+
+  return subgraph(data, (node) => ids.has(node.id));
+};
+
+// filteredGraph applies the tag and folder filters of the graph page.
+const filteredGraph = (data) => {
+  const showTags = showTagsInput ? showTagsInput.checked : true;
+  const folder = folderSelect ? folderSelect.value : "";
+
+  return subgraph(data, (node) => {
+    if (node.type === "tag") return showTags;
+    if (!folder) return true;
+    const nodeFolder = node.folder || "";
+    return nodeFolder === folder || nodeFolder.startsWith(folder + "/");
+  });
+};
+
+const subgraph = (data, keep) => {
+  const nodes = data.nodes.filter(keep).map((node) => ({ ...node }));
+  const ids = new Set(nodes.map((node) => node.id));
+  const links = data.links
+    .filter((link) => ids.has(link.source) && ids.has(link.target))
+    .map((link) => ({ ...link }));
+  return { nodes, links };
+};
+
+const fillFolders = (data) => {
+  if (!folderSelect) return;
+  const folders = new Set();
+  data.nodes.forEach((node) => {
+    if (node.folder) folders.add(node.folder);
+  });
+  [...folders].sort().forEach((folder) => {
+    const option = document.createElement("option");
+    option.value = folder;
+    option.textContent = folder;
+    folderSelect.appendChild(option);
+  });
+};
+
+const render = (data) => {
+  nodeCount = data.nodes.filter((node) => node.type !== "tag").length || 1;
+  const graph = ForceGraph()(container)
+    .graphData(isFullGraph ? filteredGraph(data) : localGraph(data))
+    .nodeId("id")
+    .nodeLabel("title")
+    .nodeAutoColorBy((node) =>
+      isFullGraph ? (node.type === "tag" ? "#" : node.folder || "/") : node.id,
+    )
+    .nodeRelSize(6)
+    .nodeCanvasObjectMode(() => "replace")
+    .nodeCanvasObject(drawNode)
+    .linkColor(getLinkColor)
+    .onNodeClick((node) => {
+      window.location.href = node.url;
+    })
+    .width(isFullGraph ? container.clientWidth : 280)
+    .height(isFullGraph ? container.clientHeight : 250);
+
+  if (isFullGraph) {
+    fillFolders(data);
+    const refilter = () => graph.graphData(filteredGraph(data));
+    if (showTagsInput) showTagsInput.addEventListener("change", refilter);
+    if (folderSelect) folderSelect.addEventListener("change", refilter);
+    window.addEventListener("resize", () => {
+      graph.width(container.clientWidth).height(container.clientHeight);
+    });
+  }
+
+  document.addEventListener("theme-change", (event) => {
+    currentTheme = event.detail;
+    graph.nodeCanvasObject(drawNode).linkColor(getLinkColor);
+  });
+};
+
+// Notes with "graph: false" have no graph container.
+if (container && container.dataset.graph) {
+  render(JSON.parse(container.dataset.graph));
+} else if (container && container.dataset.graphSrc) {
+  fetch(container.dataset.graphSrc)
+    .then((response) => response.json())
+    .then(render)
+    .catch(() => {});
+}
@@ -1,4 +1,11 @@
 const modal = document.getElementById("searchModal");
+
+// The site may be deployed under a base path; this script lives at
+// <base>/scripts/search.js.
+const basePath = new URL(document.currentScript.src).pathname.replace(
+  /scripts\/search\.js$/,
+  "",
+);
 const openBtn = document.querySelector("div.utilities button.search");
 
 openBtn.addEventListener("click", () => {
@@ -18,6 +25,7 @@ modal.addEventListener("click", (e) => {
 window.addEventListener("DOMContentLoaded", (event) => {
   new PagefindUI({
     element: "#search",
+    baseUrl: basePath,
     showSubResults: true,
     showImages: false,
     autoFocus: true,
@@ -9,7 +9,10 @@
     document.documentElement.classList.add("dark");
     const syntaxTheme = document.getElementById("syntax-theme");
     if (syntaxTheme) {
-      syntaxTheme.href = "/styles/syntax-dark.css";
+      syntaxTheme.href = syntaxTheme.href.replace(
+        /syntax-light\.css$/,
+        "syntax-dark.css",
+      );
     }
   }
 })();
@@ -18,11 +18,12 @@ document.addEventListener("DOMContentLoaded", () => {
     root.classList.toggle("dark", theme === "dark");
     localStorage.setItem(STORAGE_KEY, theme);
 
+    // Swap the file name only, the stylesheet may live under a base path.
     if (syntaxThemeLink) {
-      syntaxThemeLink.href =
-        theme === "dark"
-          ? "/styles/syntax-dark.css"
-          : "/styles/syntax-light.css";
+      syntaxThemeLink.href = syntaxThemeLink.href.replace(
+        /syntax-(light|dark)\.css$/,
+        theme === "dark" ? "syntax-dark.css" : "syntax-light.css",
+      );
     }
   }
 